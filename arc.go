@@ -0,0 +1,135 @@
+package svger
+
+import (
+	"math"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
+)
+
+// arcToCubics converts an SVG elliptical arc, given in the endpoint
+// parameterization of the SVG path "A" command, into a sequence of
+// cubic Bézier curves. Each element of the returned slice holds the
+// two control points and the end point of one curve. The curves are
+// computed in the same coordinate space as the arguments.
+//
+// The conversion follows the SVG 1.1 implementation notes (F.6.5
+// and F.6.6): out of range radii are scaled up until the arc can
+// connect the two end points, and the arc is split into pieces of at
+// most 90 degrees so each piece is closely approximated by a cubic.
+//
+// A nil result is returned when the end points coincide, in which
+// case the arc is omitted. When either radius is zero the arc
+// degenerates to a straight line and ok is false.
+func arcToCubics(p0 Tuple, rx, ry, phi float64, large, sweep bool, p1 Tuple) (curves [][3]Tuple, ok bool) {
+	if p0 == p1 {
+		return nil, true
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return nil, false
+	}
+
+	sinPhi, cosPhi := math.Sincos(phi * math.Pi / 180)
+
+	// Step 1: compute (x1', y1').
+	dx2, dy2 := (p0[0]-p1[0])/2, (p0[1]-p1[1])/2
+	x1p := cosPhi*dx2 + sinPhi*dy2
+	y1p := -sinPhi*dx2 + cosPhi*dy2
+
+	// Correct radii that are too small to span the end points.
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	// Step 2: compute (cx', cy').
+	rx2, ry2 := rx*rx, ry*ry
+	num := rx2*ry2 - rx2*y1p*y1p - ry2*x1p*x1p
+	den := rx2*y1p*y1p + ry2*x1p*x1p
+	coef := 0.0
+	if num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+
+	// Step 3: compute (cx, cy) from (cx', cy').
+	cx := cosPhi*cxp - sinPhi*cyp + (p0[0]+p1[0])/2
+	cy := sinPhi*cxp + cosPhi*cyp + (p0[1]+p1[1])/2
+
+	// Step 4: compute the start angle and the angular extent.
+	ux, uy := (x1p-cxp)/rx, (y1p-cyp)/ry
+	vx, vy := (-x1p-cxp)/rx, (-y1p-cyp)/ry
+	theta := math.Atan2(uy, ux)
+	delta := math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// Map a point on the unit circle onto the ellipse.
+	onEllipse := func(x, y float64) Tuple {
+		x, y = rx*x, ry*y
+		return Tuple{cx + cosPhi*x - sinPhi*y, cy + sinPhi*x + cosPhi*y}
+	}
+
+	n := int(math.Ceil(math.Abs(delta)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	step := delta / float64(n)
+	alpha := 4.0 / 3.0 * math.Tan(step/4)
+	for i := 0; i < n; i++ {
+		t1 := theta + float64(i)*step
+		t2 := t1 + step
+		s1, c1 := math.Sincos(t1)
+		s2, c2 := math.Sincos(t2)
+		end := onEllipse(c2, s2)
+		if i == n-1 {
+			end = p1
+		}
+		curves = append(curves, [3]Tuple{
+			onEllipse(c1-alpha*s1, s1+alpha*c1),
+			onEllipse(c2+alpha*s2, s2-alpha*c2),
+			end,
+		})
+	}
+	return curves, true
+}
+
+// ellipseCubics returns four cubic Bézier curves approximating an
+// axis aligned ellipse. The curves start and end at the point
+// (cx+rx, cy) and proceed in the direction of increasing angle.
+// Since an affine transform of a Bézier curve is the Bézier curve of
+// the transformed control points, the result remains correct after
+// any transform, unlike a center and radius.
+func ellipseCubics(cx, cy, rx, ry float64) [][3]Tuple {
+	k := 4.0 / 3.0 * (math.Sqrt2 - 1)
+	kx, ky := k*rx, k*ry
+	return [][3]Tuple{
+		{{cx + rx, cy + ky}, {cx + kx, cy + ry}, {cx, cy + ry}},
+		{{cx - kx, cy + ry}, {cx - rx, cy + ky}, {cx - rx, cy}},
+		{{cx - rx, cy - ky}, {cx - kx, cy - ry}, {cx, cy - ry}},
+		{{cx + kx, cy - ry}, {cx + rx, cy - ky}, {cx + rx, cy}},
+	}
+}
+
+// curveInstruction returns a CurveInstruction for the transformed
+// control and end points, c, of a cubic Bézier curve.
+func curveInstruction(t *mt.Transform, c [3]Tuple) *DrawingInstruction {
+	c1x, c1y := t.Apply(c[0][0], c[0][1])
+	c2x, c2y := t.Apply(c[1][0], c[1][1])
+	tx, ty := t.Apply(c[2][0], c[2][1])
+	return &DrawingInstruction{
+		Kind: CurveInstruction,
+		CurvePoints: &CurvePoints{
+			C1: &Tuple{c1x, c1y},
+			C2: &Tuple{c2x, c2y},
+			T:  &Tuple{tx, ty},
+		},
+	}
+}
@@ -2,104 +2,86 @@ package svger
 
 import "math"
 
-// cubicBezier
-type cubicBezier struct {
-	controlpoints [4][2]float64
-	vertices      [][2]float64
-	level         int
+// maxFlattenDepth limits the subdivision of a cubic Bézier curve.
+// Each subdivision reduces the deviation of the control points from
+// the chord by about a factor of four, so it is only reached when the
+// tolerance is minute compared to the size of the curve.
+const maxFlattenDepth = 24
+
+// FlattenCubic approximates the cubic Bézier curve from p0 to p3,
+// with control points p1 and p2, by straight lines. It returns the
+// points the lines connect, excluding p0 and ending with p3. No point
+// of the curve is further than tolerance from the lines.
+//
+// The curve lies within the convex hull of its control points, so
+// once both of the inner control points are within tolerance of the
+// chord from p0 to p3, the chord is a close enough approximation.
+// Otherwise the curve is split in two and each half is flattened.
+//
+// The tolerance must be positive, otherwise nil is returned.
+func FlattenCubic(p0, p1, p2, p3 Tuple, tolerance float64) []Tuple {
+	if !(tolerance > 0) {
+		return nil
+	}
+	return flattenCubic(nil, p0, p1, p2, p3, tolerance, 0)
 }
 
-func (c *cubicBezier) interpolate(n int) [][2]float64 {
-	var vertices [][2]float64
-	for i := 0; i < n+1; i++ {
-		var t float64
-		t = float64(i) / float64(n)
-
-		var v [2]float64
-		v[0] = (1-t)*(1-t)*(1-t)*c.controlpoints[0][0] + 3*(1-t)*(1-t)*t*c.controlpoints[1][0] + 3*(1-t)*t*t*c.controlpoints[2][0] + t*t*t*c.controlpoints[3][0]
-		v[1] = (1-t)*(1-t)*(1-t)*c.controlpoints[0][1] + 3*(1-t)*(1-t)*t*c.controlpoints[1][1] + 3*(1-t)*t*t*c.controlpoints[2][1] + t*t*t*c.controlpoints[3][1]
-		vertices = append(vertices, v)
+// flattenCubic appends the flattened curve to pts.
+func flattenCubic(pts []Tuple, p0, p1, p2, p3 Tuple, tolerance float64, depth int) []Tuple {
+	if depth >= maxFlattenDepth || !(segmentDistance(p1, p0, p3) > tolerance || segmentDistance(p2, p0, p3) > tolerance) {
+		return append(pts, p3)
 	}
-	return vertices
+	// Split the curve at its midpoint with de Casteljau's
+	// algorithm.
+	p01, p12, p23 := midpoint(p0, p1), midpoint(p1, p2), midpoint(p2, p3)
+	p012, p123 := midpoint(p01, p12), midpoint(p12, p23)
+	m := midpoint(p012, p123)
+	pts = flattenCubic(pts, p0, p01, p012, m, tolerance, depth+1)
+	return flattenCubic(pts, m, p123, p23, p3, tolerance, depth+1)
 }
 
-func (c *cubicBezier) recursiveInterpolate(limit int, level int) [][2]float64 {
-	c.level = level
-	//	fmt.Println(level)
-	var m12 [2]float64
-	var m23 [2]float64
-	var m34 [2]float64
-	var m123 [2]float64
-	var m234 [2]float64
-	var m1234 [2]float64
-	m12[0] = (c.controlpoints[0][0] + c.controlpoints[1][0]) / 2.0
-	m12[1] = (c.controlpoints[0][1] + c.controlpoints[1][1]) / 2.0
-
-	m23[0] = (c.controlpoints[1][0] + c.controlpoints[2][0]) / 2.0
-	m23[1] = (c.controlpoints[1][1] + c.controlpoints[2][1]) / 2.0
-
-	m34[0] = (c.controlpoints[2][0] + c.controlpoints[3][0]) / 2.0
-	m34[1] = (c.controlpoints[2][1] + c.controlpoints[3][1]) / 2.0
-
-	m123[0] = (m12[0] + m23[0]) / 2.0
-	m123[1] = (m12[1] + m23[1]) / 2.0
-
-	m234[0] = (m23[0] + m34[0]) / 2.0
-	m234[1] = (m23[1] + m34[1]) / 2.0
-
-	m1234[0] = (m123[0] + m234[0]) / 2.0
-	m1234[1] = (m123[1] + m234[1]) / 2.0
-
-	if limit == 0 {
-		var vertices [][2]float64
-		vertices = append(vertices, c.controlpoints[0])
-		vertices = append(vertices, m1234)
-		vertices = append(vertices, c.controlpoints[3])
-		return vertices
+// FlattenCircle approximates the circle with center c and radius r by
+// a closed polygon. It returns the vertices of the polygon, starting
+// and ending with the point (c[0]+r, c[1]) and proceeding in the
+// direction of increasing angle. No point of the circle is further
+// than tolerance from the polygon.
+//
+// The tolerance must be positive, otherwise nil is returned.
+func FlattenCircle(c Tuple, r, tolerance float64) []Tuple {
+	if !(tolerance > 0) {
+		return nil
 	}
-
-	a12 := math.Atan2(c.controlpoints[0][1]-c.controlpoints[1][1], c.controlpoints[1][0]-c.controlpoints[0][0])
-	a34 := math.Atan2(c.controlpoints[2][1]-c.controlpoints[3][1], c.controlpoints[3][0]-c.controlpoints[2][0])
-	a1234 := math.Atan2(m123[1]-m1234[1], m1234[0]-m123[0])
-
-	//	fmt.Println("a12", a12*180.0/math.Pi)
-	//	fmt.Println("a34", a34*180.0/math.Pi)
-	//	fmt.Println("a1234", a1234*180.0/math.Pi)
-
-	d1 := math.Abs(a1234 - a12)
-	d2 := math.Abs(a34 - a1234)
-	if d1 >= math.Pi {
-		d1 = 2*math.Pi - d1
+	r = math.Abs(r)
+	n := 4
+	if tolerance < r {
+		// A chord spanning angle a deviates from the circle
+		// by r*(1-cos(a/2)).
+		n = int(math.Ceil(math.Pi / math.Acos(1-tolerance/r)))
+		if n < 4 {
+			n = 4
+		}
 	}
-	if d2 >= math.Pi {
-		d2 = 2*math.Pi - d2
+	pts := make([]Tuple, 0, n+1)
+	for i := 0; i < n; i++ {
+		s, k := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		pts = append(pts, Tuple{c[0] + r*k, c[1] + r*s})
 	}
+	return append(pts, pts[0])
+}
 
-	//	fmt.Println("angle d1", d1*180.0/math.Pi)
-	//fmt.Println("angle d2", d2*180.0/math.Pi)
-
-	if d1+d2 > 5.0*math.Pi/180.0 {
-		var vertices [][2]float64
-		var c1 cubicBezier
-		c1.controlpoints[0] = c.controlpoints[0]
-		c1.controlpoints[1] = m12
-		c1.controlpoints[2] = m123
-		c1.controlpoints[3] = m1234
-
-		vertices = append(vertices, c1.recursiveInterpolate(limit-1, c.level+1)...)
+// midpoint returns the point halfway between a and b.
+func midpoint(a, b Tuple) Tuple {
+	return Tuple{(a[0] + b[0]) / 2, (a[1] + b[1]) / 2}
+}
 
-		var c2 cubicBezier
-		c2.controlpoints[0] = m1234
-		c2.controlpoints[1] = m234
-		c2.controlpoints[2] = m34
-		c2.controlpoints[3] = c.controlpoints[3]
-		vertices = append(vertices, c2.recursiveInterpolate(limit-1, c.level+1)...)
-		return vertices
+// segmentDistance returns the distance of point p from the line
+// segment from a to b.
+func segmentDistance(p, a, b Tuple) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	px, py := p[0]-a[0], p[1]-a[1]
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t := math.Max(0, math.Min(1, (px*dx+py*dy)/l2))
+		px, py = px-t*dx, py-t*dy
 	}
-
-	var vertices [][2]float64
-	vertices = append(vertices, c.controlpoints[0])
-	vertices = append(vertices, m1234)
-	vertices = append(vertices, c.controlpoints[3])
-	return vertices
+	return math.Hypot(px, py)
 }
@@ -1,68 +1,103 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+
 	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // Circle is an SVG circle element
 type Circle struct {
 	ID        string  `xml:"id,attr"`
+	Class     string  `xml:"class,attr"`
 	Transform string  `xml:"transform,attr"`
 	Style     string  `xml:"style,attr"`
-	Cx        float64 `xml:"cx,attr"`
-	Cy        float64 `xml:"cy,attr"`
-	Radius    float64 `xml:"r,attr"`
+	Cx        float64 `xml:"-"`
+	Cy        float64 `xml:"-"`
+	Radius    float64 `xml:"-"`
 	Fill      string  `xml:"fill,attr"`
 	Stroke    string  `xml:"stroke,attr"`
 
-	transform mtransform.Transform
+	transform mt.Transform
 	group     *Group
+	attrs     []xml.Attr
 }
 
 // ParseDrawingInstructions implements the DrawingInstructionParser
-// interface
+// interface. A circle is drawn with a CircleInstruction, unless its
+// transform doesn't preserve its shape.
 func (c *Circle) ParseDrawingInstructions() chan *DrawingInstruction {
-	if c.Fill == "" && c.group.Fill != "" {
-		c.Fill = c.group.Fill
-	}
-	if c.Stroke == "" && c.group.Stroke != "" {
-		c.Stroke = c.group.Stroke
-	}
-	scale := 1.0
-	if c.group.Owner != nil {
-		scale = c.group.Owner.scale
+	if c.group == nil {
+		c.group = new(Group)
+		temp := mt.Identity()
+		c.group.Transform = &temp
 	}
+	cs := c.group.elementStyle("circle", c.attrs)
 	pdp := newPathDParse()
-	circTransform := mt.Identity()
-	if c.Transform != "" {
-		if ct, err := parseTransform(c.Transform); err == nil {
-			circTransform = ct
-		}
-	}
+	circTransform, terr := parseTransform(c.Transform)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, *c.group.Transform)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, circTransform)
 
 	draw := make(chan *DrawingInstruction)
 	go func() {
 		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
 
-		x, y := pdp.transform.Apply(c.Cx, c.Cy)
-		r := scale * c.Radius
-		s := scale * c.group.StrokeWidth
-
-		draw <- &DrawingInstruction{
-			Kind:   CircleInstruction,
-			M:      &Tuple{x, y},
-			Radius: &r,
+		// A zero radius disables rendering of the element.
+		if c.Radius <= 0 {
+			return
 		}
-		draw <- &DrawingInstruction{
-			Kind:        PaintInstruction,
-			StrokeWidth: &s,
-			Stroke:      &c.Stroke,
-			Fill:        &c.Fill,
+
+		if isSimilarity(&pdp.transform) {
+			x, y := pdp.transform.Apply(c.Cx, c.Cy)
+			r := transformScale(&pdp.transform) * c.Radius
+			draw <- &DrawingInstruction{
+				Kind:   CircleInstruction,
+				M:      &Tuple{x, y},
+				Radius: &r,
+			}
+		} else {
+			// The transform distorts the circle into an
+			// ellipse, which is drawn as for an ellipse
+			// element.
+			x, y := pdp.transform.Apply(c.Cx+c.Radius, c.Cy)
+			draw <- &DrawingInstruction{
+				Kind: MoveInstruction,
+				M:    &Tuple{x, y},
+			}
+			for _, cp := range ellipseCubics(c.Cx, c.Cy, c.Radius, c.Radius) {
+				draw <- curveInstruction(&pdp.transform, cp)
+			}
+			draw <- &DrawingInstruction{
+				Kind: CloseInstruction,
+				M:    &Tuple{x, y},
+			}
 		}
+		draw <- cs.paintInstruction(c.group.strokeScale(&pdp.transform))
 	}()
 
 	return draw
 }
+
+// updateStyle sets the exported style fields of the circle to their
+// computed values.
+func (c *Circle) updateStyle() {
+	cs := c.group.elementStyle("circle", c.attrs)
+	c.Fill, c.Stroke = cs.Fill, cs.Stroke
+}
+
+// setLengths resolves the geometry attributes of the circle within a
+// viewport of size vp.
+func (c *Circle) setLengths(vp Tuple) {
+	setLengths(c.attrs, vp, map[string]*float64{
+		"cx": &c.Cx,
+		"cy": &c.Cy,
+		"r":  &c.Radius,
+	})
+}
@@ -0,0 +1,614 @@
+// Package clip performs boolean operations (union, intersection,
+// difference and exclusive or) on polygonal shapes, such as the
+// flattened svger.Segments of an image.
+//
+// The operations split every edge where it meets another, merge
+// coincident edges, and keep the edges that separate the inside of the
+// result from its outside. Whether a region is inside each shape is
+// decided by its winding number and the fill rule of the shape, so
+// self-intersecting and overlapping contours are handled as SVG fills
+// them.
+package clip
+
+import (
+	"math"
+	"sort"
+
+	"zappem.net/pub/graphics/svger"
+)
+
+// FillRule determines which regions the contours of a shape enclose.
+type FillRule int
+
+const (
+	// NonZero regions have a non-zero winding number.
+	NonZero FillRule = iota
+	// EvenOdd regions have an odd winding number.
+	EvenOdd
+)
+
+// ParseFillRule returns the FillRule named by an SVG fill-rule value,
+// such as the FillRule of an svger.Group or svger.Segment.
+func ParseFillRule(rule string) FillRule {
+	if rule == "evenodd" {
+		return EvenOdd
+	}
+	return NonZero
+}
+
+// inside returns true if a winding number is inside a shape.
+func (r FillRule) inside(w int) bool {
+	if r == EvenOdd {
+		return w%2 != 0
+	}
+	return w != 0
+}
+
+// Shape is the region enclosed by closed Contours, according to Rule.
+type Shape struct {
+	Contours [][][2]float64
+	Rule     FillRule
+}
+
+// Shapes returns the shape filled by each element that drew segs, as
+// numbered by their Element, such as the segments returned by
+// svger.Svg.ParseSegments or svger.StrokeOutlines. Each shape is
+// bounded by the closed segments of its element and filled with their
+// FillRule. Open segments, and the segments of elements with a Fill of
+// "none", are ignored.
+func Shapes(segs []svger.Segment) []Shape {
+	var shapes []Shape
+	index := make(map[int]int)
+	for _, seg := range segs {
+		if !seg.Closed || len(seg.Points) < 3 || seg.Fill == "none" {
+			continue
+		}
+		i, ok := index[seg.Element]
+		if !ok {
+			i = len(shapes)
+			index[seg.Element] = i
+			shapes = append(shapes, Shape{Rule: ParseFillRule(seg.FillRule)})
+		}
+		shapes[i].Contours = append(shapes[i].Contours, seg.Points)
+	}
+	return shapes
+}
+
+// GroupShapes returns the union of the shapes filled by the elements
+// of a group, with curves flattened to within tolerance (see
+// svger.Flatten).
+func GroupShapes(g *svger.Group, tolerance float64) ([]Polygon, error) {
+	segs, err := g.ParseSegments(tolerance)
+	if err != nil {
+		return nil, err
+	}
+	return Union(Shapes(segs)...), nil
+}
+
+// Polygon is a region of the result of an operation. Its Outer
+// contour has a positive signed (shoelace) area, which is
+// counterclockwise when y increases upwards, and clockwise on an SVG
+// canvas. Its Holes have negative areas. Regions within a hole are
+// separate Polygons.
+type Polygon struct {
+	Outer [][2]float64
+	Holes [][][2]float64
+}
+
+// Area returns the area of the polygon, excluding its holes.
+func (p Polygon) Area() float64 {
+	a := area(p.Outer)
+	for _, h := range p.Holes {
+		a += area(h)
+	}
+	return a
+}
+
+// Segments returns the contours of polys as closed segments, filled
+// with fill. Since holes run opposite to their outer contours, the
+// nonzero and evenodd fill rules fill the segments alike.
+func Segments(polys []Polygon, fill string) []svger.Segment {
+	var segs []svger.Segment
+	add := func(pts [][2]float64) {
+		segs = append(segs, svger.Segment{Closed: true, Points: pts, Fill: fill, FillRule: "nonzero", Stroke: "none"})
+	}
+	for _, p := range polys {
+		add(p.Outer)
+		for _, h := range p.Holes {
+			add(h)
+		}
+	}
+	return segs
+}
+
+// Union returns the region inside any of shapes.
+func Union(shapes ...Shape) []Polygon {
+	return combine(shapes, func(w map[int]int) bool {
+		for s, n := range w {
+			if shapes[s].Rule.inside(n) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Intersection returns the region inside both a and b.
+func Intersection(a, b Shape) []Polygon {
+	return combine([]Shape{a, b}, func(w map[int]int) bool {
+		return a.Rule.inside(w[0]) && b.Rule.inside(w[1])
+	})
+}
+
+// Difference returns the region inside a but not b.
+func Difference(a, b Shape) []Polygon {
+	return combine([]Shape{a, b}, func(w map[int]int) bool {
+		return a.Rule.inside(w[0]) && !b.Rule.inside(w[1])
+	})
+}
+
+// Xor returns the region inside exactly one of a and b.
+func Xor(a, b Shape) []Polygon {
+	return combine([]Shape{a, b}, func(w map[int]int) bool {
+		return a.Rule.inside(w[0]) != b.Rule.inside(w[1])
+	})
+}
+
+// point is a vertex of the planar arrangement of the edges.
+type point [2]float64
+
+// less orders points by x, then y.
+func (p point) less(q point) bool {
+	return p[0] < q[0] || p[0] == q[0] && p[1] < q[1]
+}
+
+// edge is a directed edge of the contours of a shape.
+type edge struct {
+	a, b  point
+	shape int
+}
+
+// count is the number of edges of a shape, net of their directions,
+// along a merged edge.
+type count struct {
+	shape, n int
+}
+
+// merged is an edge of the arrangement, from a to b with a.less(b),
+// along which edges of the shapes run.
+type merged struct {
+	a, b   point
+	counts []count
+}
+
+// combine returns the region of the plane for which inside returns
+// true, given the winding numbers of the shapes about its points.
+// Shapes with a winding number of zero are absent from the map.
+func combine(shapes []Shape, inside func(w map[int]int) bool) []Polygon {
+	var edges []edge
+	scale := 1.0
+	for s, shape := range shapes {
+		for _, c := range shape.Contours {
+			for i, p := range c {
+				scale = math.Max(scale, math.Max(math.Abs(p[0]), math.Abs(p[1])))
+				edges = append(edges, edge{a: p, b: c[(i+1)%len(c)], shape: s})
+			}
+		}
+	}
+	// Vertices are snapped to a grid of size q, so that the
+	// points where edges meet are identical. A power of two keeps
+	// coordinates that are already on the grid exact.
+	q := math.Pow(2, math.Floor(math.Log2(scale*1e-9)))
+	for i := range edges {
+		edges[i].a = snap(edges[i].a, q)
+		edges[i].b = snap(edges[i].b, q)
+	}
+	edges = split(edges, q)
+	ms := merge(edges)
+
+	ix := newIndex(ms)
+	var out []merged
+	for i, m := range ms {
+		left, right := ix.windings(i, q)
+		if l, r := inside(left), inside(right); l != r {
+			if r {
+				m.a, m.b = m.b, m.a
+			}
+			out = append(out, m)
+		}
+	}
+	return nest(trace(out, q), q)
+}
+
+// snap rounds a point to the grid of size q.
+func snap(p point, q float64) point {
+	return point{math.Round(p[0]/q) * q, math.Round(p[1]/q) * q}
+}
+
+// split splits edges wherever they meet other edges, until no edge
+// crosses or touches the interior of another. Since the cuts are
+// points of the grid of size q, edges can't be split indefinitely.
+func split(edges []edge, q float64) []edge {
+	for {
+		cuts := make([][]point, len(edges))
+		order := make([]int, len(edges))
+		for i := range order {
+			order[i] = i
+		}
+		minX := func(e edge) float64 { return math.Min(e.a[0], e.b[0]) }
+		sort.Slice(order, func(i, j int) bool { return minX(edges[order[i]]) < minX(edges[order[j]]) })
+		changed := false
+		for oi, i := range order {
+			e := edges[i]
+			maxX := math.Max(e.a[0], e.b[0]) + q
+			for _, j := range order[oi+1:] {
+				f := edges[j]
+				if minX(f) > maxX {
+					break
+				}
+				if math.Min(f.a[1], f.b[1]) > math.Max(e.a[1], e.b[1])+q || math.Min(e.a[1], e.b[1]) > math.Max(f.a[1], f.b[1])+q {
+					continue
+				}
+				for _, c := range intersect(e, f, q) {
+					if c != e.a && c != e.b {
+						cuts[i] = append(cuts[i], c)
+						changed = true
+					}
+					if c != f.a && c != f.b {
+						cuts[j] = append(cuts[j], c)
+						changed = true
+					}
+				}
+			}
+		}
+		if !changed {
+			return edges
+		}
+		var next []edge
+		for i, e := range edges {
+			cs := cuts[i]
+			d := point{e.b[0] - e.a[0], e.b[1] - e.a[1]}
+			along := func(p point) float64 { return (p[0]-e.a[0])*d[0] + (p[1]-e.a[1])*d[1] }
+			sort.Slice(cs, func(i, j int) bool { return along(cs[i]) < along(cs[j]) })
+			a := e.a
+			for _, c := range append(cs, e.b) {
+				if c != a {
+					next = append(next, edge{a: a, b: c, shape: e.shape})
+					a = c
+				}
+			}
+		}
+		edges = next
+	}
+}
+
+// intersect returns the snapped points where edges e and f meet,
+// which may include their endpoints.
+func intersect(e, f edge, q float64) []point {
+	var pts []point
+	for _, p := range []point{f.a, f.b} {
+		if onEdge(p, e, q) {
+			pts = append(pts, p)
+		}
+	}
+	for _, p := range []point{e.a, e.b} {
+		if onEdge(p, f, q) {
+			pts = append(pts, p)
+		}
+	}
+	if len(pts) != 0 {
+		return pts
+	}
+	d1 := orient(f.a, f.b, e.a)
+	d2 := orient(f.a, f.b, e.b)
+	d3 := orient(e.a, e.b, f.a)
+	d4 := orient(e.a, e.b, f.b)
+	if (d1 > 0) == (d2 > 0) || (d3 > 0) == (d4 > 0) || d1 == 0 || d2 == 0 || d3 == 0 || d4 == 0 {
+		return nil
+	}
+	t := d1 / (d1 - d2)
+	return []point{snap(point{e.a[0] + t*(e.b[0]-e.a[0]), e.a[1] + t*(e.b[1]-e.a[1])}, q)}
+}
+
+// onEdge returns true if p is within q of the interior of e.
+func onEdge(p point, e edge, q float64) bool {
+	if p == e.a || p == e.b {
+		return false
+	}
+	dx, dy := e.b[0]-e.a[0], e.b[1]-e.a[1]
+	l2 := dx*dx + dy*dy
+	t := ((p[0]-e.a[0])*dx + (p[1]-e.a[1])*dy) / l2
+	if t <= 0 || t >= 1 {
+		return false
+	}
+	return math.Abs(orient(e.a, e.b, p)) <= q*math.Sqrt(l2)
+}
+
+// orient returns twice the signed area of the triangle a, b, c, which
+// is positive if c is to the left of the direction from a to b.
+func orient(a, b, c point) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// merge combines the edges that join the same pair of points.
+func merge(edges []edge) []merged {
+	type key struct{ a, b point }
+	index := make(map[key]int)
+	var ms []merged
+	for _, e := range edges {
+		a, b, n := e.a, e.b, 1
+		if b.less(a) {
+			a, b, n = b, a, -1
+		}
+		k := key{a, b}
+		i, ok := index[k]
+		if !ok {
+			i = len(ms)
+			index[k] = i
+			ms = append(ms, merged{a: a, b: b})
+		}
+		m := &ms[i]
+		found := false
+		for j := range m.counts {
+			if m.counts[j].shape == e.shape {
+				m.counts[j].n += n
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.counts = append(m.counts, count{shape: e.shape, n: n})
+		}
+	}
+	// Edges whose directions cancel don't bound anything.
+	kept := ms[:0]
+	for _, m := range ms {
+		cs := m.counts[:0]
+		for _, c := range m.counts {
+			if c.n != 0 {
+				cs = append(cs, c)
+			}
+		}
+		if m.counts = cs; len(cs) != 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// index finds the merged edges that span a y coordinate.
+type index struct {
+	ms           []merged
+	minY, height float64
+	buckets      [][]int
+}
+
+func newIndex(ms []merged) *index {
+	ix := &index{ms: ms, minY: math.Inf(1)}
+	maxY := math.Inf(-1)
+	for _, m := range ms {
+		ix.minY = math.Min(ix.minY, math.Min(m.a[1], m.b[1]))
+		maxY = math.Max(maxY, math.Max(m.a[1], m.b[1]))
+	}
+	n := int(math.Sqrt(float64(len(ms)))) + 1
+	ix.buckets = make([][]int, n)
+	ix.height = (maxY - ix.minY) / float64(n)
+	for i, m := range ms {
+		lo, hi := ix.bucket(math.Min(m.a[1], m.b[1])), ix.bucket(math.Max(m.a[1], m.b[1]))
+		for b := lo; b <= hi; b++ {
+			ix.buckets[b] = append(ix.buckets[b], i)
+		}
+	}
+	return ix
+}
+
+// bucket returns the bucket that holds y.
+func (ix *index) bucket(y float64) int {
+	if !(ix.height > 0) {
+		return 0
+	}
+	b := int((y - ix.minY) / ix.height)
+	if b >= len(ix.buckets) {
+		b = len(ix.buckets) - 1
+	}
+	return b
+}
+
+// windings returns the winding numbers of the shapes about the points
+// to the left and right of merged edge i, from its a to its b.
+//
+// A ray is cast in the +x direction from the middle of the edge. The
+// crossings of the other edges are counted as if the ray were
+// infinitesimally above its y coordinate, so they sum to the winding
+// numbers of the side of the edge in the +x direction or, for a
+// horizontal edge, the +y direction. Crossing the edge from its right
+// to its left increases each winding number by the count of the edge.
+func (ix *index) windings(i int, q float64) (left, right map[int]int) {
+	m := ix.ms[i]
+	mid := point{(m.a[0] + m.b[0]) / 2, (m.a[1] + m.b[1]) / 2}
+	w := make(map[int]int)
+	for _, j := range ix.buckets[ix.bucket(mid[1])] {
+		if j == i {
+			continue
+		}
+		f := ix.ms[j]
+		sign := 1
+		a, b := f.a, f.b
+		if b[1] < a[1] {
+			a, b, sign = b, a, -1
+		}
+		if !(a[1] <= mid[1] && mid[1] < b[1]) {
+			continue
+		}
+		if x := a[0] + (mid[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]); x <= mid[0] {
+			continue
+		}
+		for _, c := range f.counts {
+			w[c.shape] += sign * c.n
+		}
+	}
+	other := make(map[int]int)
+	for s, n := range w {
+		other[s] = n
+	}
+	// The ray side is the left side if the left normal, (-dy,
+	// dx), points in its direction.
+	dx, dy := m.b[0]-m.a[0], m.b[1]-m.a[1]
+	rayLeft := -dy > 0
+	if dy == 0 {
+		rayLeft = dx > 0
+	}
+	for _, c := range m.counts {
+		if rayLeft {
+			other[c.shape] -= c.n
+		} else {
+			other[c.shape] += c.n
+		}
+	}
+	clean := func(w map[int]int) map[int]int {
+		for s, n := range w {
+			if n == 0 {
+				delete(w, s)
+			}
+		}
+		return w
+	}
+	if rayLeft {
+		return clean(w), clean(other)
+	}
+	return clean(other), clean(w)
+}
+
+// trace joins directed edges, each with the inside of the result on
+// its left, into closed loops. Where several edges leave a vertex,
+// the loop follows the one that keeps it hugging the region on its
+// left, so loops that touch at a vertex stay separate.
+func trace(ms []merged, q float64) [][][2]float64 {
+	from := make(map[point][]int)
+	for i, m := range ms {
+		from[m.a] = append(from[m.a], i)
+	}
+	used := make([]bool, len(ms))
+	var loops [][][2]float64
+	for start := range ms {
+		if used[start] {
+			continue
+		}
+		loop := [][2]float64{ms[start].a}
+		cur := start
+		for {
+			used[cur] = true
+			m := ms[cur]
+			back := math.Atan2(m.a[1]-m.b[1], m.a[0]-m.b[0])
+			next, best := -1, math.Inf(1)
+			for _, j := range from[m.b] {
+				if used[j] && j != start {
+					continue
+				}
+				n := ms[j]
+				// The clockwise angle from the direction back
+				// along the edge.
+				d := back - math.Atan2(n.b[1]-n.a[1], n.b[0]-n.a[0])
+				for d <= 0 {
+					d += 2 * math.Pi
+				}
+				if d < best {
+					next, best = j, d
+				}
+			}
+			if next == start || next < 0 {
+				break
+			}
+			loop = append(loop, m.b)
+			cur = next
+		}
+		if loop = simplify(loop, q); len(loop) > 2 && area(loop) != 0 {
+			loops = append(loops, loop)
+		}
+	}
+	return loops
+}
+
+// simplify removes the vertices of a loop that lie on the line
+// joining their neighbors.
+func simplify(loop [][2]float64, q float64) [][2]float64 {
+	for changed := true; changed && len(loop) > 2; {
+		changed = false
+		var out [][2]float64
+		n := len(loop)
+		for i, p := range loop {
+			a, b := point(loop[(i+n-1)%n]), point(loop[(i+1)%n])
+			if len(out) > 0 {
+				a = point(out[len(out)-1])
+			}
+			dx, dy := b[0]-a[0], b[1]-a[1]
+			l := math.Hypot(dx, dy)
+			along := (p[0]-a[0])*dx + (p[1]-a[1])*dy
+			if along > 0 && along < l*l && math.Abs(orient(a, b, point(p))) <= q*l {
+				changed = true
+				continue
+			}
+			out = append(out, p)
+		}
+		loop = out
+	}
+	return loop
+}
+
+// area returns the signed (shoelace) area of a loop.
+func area(loop [][2]float64) float64 {
+	a := 0.0
+	for i, p := range loop {
+		n := loop[(i+1)%len(loop)]
+		a += p[0]*n[1] - n[0]*p[1]
+	}
+	return a / 2
+}
+
+// nest assigns each hole to the smallest outer loop that contains it.
+func nest(loops [][][2]float64, q float64) []Polygon {
+	var polys []Polygon
+	var holes [][][2]float64
+	for _, l := range loops {
+		if area(l) > 0 {
+			polys = append(polys, Polygon{Outer: l})
+		} else {
+			holes = append(holes, l)
+		}
+	}
+	sort.Slice(polys, func(i, j int) bool { return area(polys[i].Outer) < area(polys[j].Outer) })
+	for _, h := range holes {
+		for i := range polys {
+			if contains(polys[i].Outer, h, q) {
+				polys[i].Holes = append(polys[i].Holes, h)
+				break
+			}
+		}
+	}
+	return polys
+}
+
+// contains returns true if the vertices of hole that are not on the
+// boundary of outer are inside it.
+func contains(outer, hole [][2]float64, q float64) bool {
+	for _, p := range hole {
+		onBoundary := false
+		w := 0
+		for i, a := range outer {
+			b := outer[(i+1)%len(outer)]
+			if onEdge(point(p), edge{a: a, b: b}, q) || p == a {
+				onBoundary = true
+				break
+			}
+			if a[1] <= p[1] && b[1] > p[1] && orient(a, b, point(p)) > 0 {
+				w++
+			} else if a[1] > p[1] && b[1] <= p[1] && orient(a, b, point(p)) < 0 {
+				w--
+			}
+		}
+		if !onBoundary {
+			return w != 0
+		}
+	}
+	return false
+}
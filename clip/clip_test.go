@@ -0,0 +1,242 @@
+package clip
+
+import (
+	"math"
+	"testing"
+
+	"zappem.net/pub/graphics/svger"
+)
+
+// square returns a square contour with corner (x, y) and side d,
+// counterclockwise when y increases upwards unless reversed.
+func square(x, y, d float64, reversed bool) [][2]float64 {
+	c := [][2]float64{{x, y}, {x + d, y}, {x + d, y + d}, {x, y + d}}
+	if reversed {
+		c[1], c[3] = c[3], c[1]
+	}
+	return c
+}
+
+func totalArea(polys []Polygon) float64 {
+	a := 0.0
+	for _, p := range polys {
+		a += p.Area()
+	}
+	return a
+}
+
+func TestOperations(t *testing.T) {
+	a := Shape{Contours: [][][2]float64{square(0, 0, 2, false)}}
+	b := Shape{Contours: [][][2]float64{square(1, 1, 2, true)}}
+	vs := []struct {
+		name  string
+		polys []Polygon
+		n     int
+		area  float64
+	}{
+		{"union", Union(a, b), 1, 7},
+		{"intersection", Intersection(a, b), 1, 1},
+		{"difference", Difference(a, b), 1, 3},
+		{"xor", Xor(a, b), 2, 6},
+	}
+	for _, v := range vs {
+		if len(v.polys) != v.n {
+			t.Errorf("%s: got %d polygons, want %d: %v", v.name, len(v.polys), v.n, v.polys)
+		}
+		if got := totalArea(v.polys); math.Abs(got-v.area) > 1e-9 {
+			t.Errorf("%s: area %g, want %g: %v", v.name, got, v.area, v.polys)
+		}
+		for _, p := range v.polys {
+			if area(p.Outer) <= 0 || len(p.Holes) != 0 {
+				t.Errorf("%s: bad polygon %v", v.name, p)
+			}
+		}
+	}
+	if p := Intersection(a, b); len(p) == 1 && len(p[0].Outer) != 4 {
+		t.Errorf("intersection is %v, want a square", p)
+	}
+}
+
+func TestFillRules(t *testing.T) {
+	// Two overlapping contours of the same direction, and a square
+	// ring with both contours in the same direction.
+	overlap := [][][2]float64{square(0, 0, 2, false), square(1, 0, 2, false)}
+	ring := [][][2]float64{square(0, 0, 3, false), square(1, 1, 1, false)}
+	vs := []struct {
+		name     string
+		contours [][][2]float64
+		rule     FillRule
+		area     float64
+		holes    int
+	}{
+		{"overlap nonzero", overlap, NonZero, 6, 0},
+		{"overlap evenodd", overlap, EvenOdd, 4, 0},
+		{"ring nonzero", ring, NonZero, 9, 0},
+		{"ring evenodd", ring, EvenOdd, 8, 1},
+	}
+	for _, v := range vs {
+		polys := Union(Shape{Contours: v.contours, Rule: v.rule})
+		if got := totalArea(polys); math.Abs(got-v.area) > 1e-9 {
+			t.Errorf("%s: area %g, want %g: %v", v.name, got, v.area, polys)
+		}
+		holes := 0
+		for _, p := range polys {
+			holes += len(p.Holes)
+			for _, h := range p.Holes {
+				if area(h) >= 0 {
+					t.Errorf("%s: hole %v has non-negative area", v.name, h)
+				}
+			}
+		}
+		if holes != v.holes {
+			t.Errorf("%s: got %d holes, want %d: %v", v.name, holes, v.holes, polys)
+		}
+	}
+	if ParseFillRule("evenodd") != EvenOdd || ParseFillRule("nonzero") != NonZero || ParseFillRule("") != NonZero {
+		t.Error("ParseFillRule failed")
+	}
+}
+
+func TestNesting(t *testing.T) {
+	// A frame with an island in its hole, and a square touching the
+	// frame along part of an edge.
+	frame := Shape{Contours: [][][2]float64{square(0, 0, 10, false), square(2, 2, 6, true)}}
+	island := Shape{Contours: [][][2]float64{square(4, 4, 2, false)}}
+	touching := Shape{Contours: [][][2]float64{square(10, 3, 2, false)}}
+	polys := Union(frame, island, touching)
+	if len(polys) != 2 {
+		t.Fatalf("got %d polygons, want 2: %v", len(polys), polys)
+	}
+	if got := totalArea(polys); math.Abs(got-(100-36+4+4)) > 1e-9 {
+		t.Errorf("area %g, want 72: %v", got, polys)
+	}
+	small, big := polys[0], polys[1]
+	if len(small.Holes) != 0 || math.Abs(small.Area()-4) > 1e-9 {
+		t.Errorf("island is %v", small)
+	}
+	if len(big.Holes) != 1 || len(big.Holes[0]) != 4 || len(big.Outer) != 8 {
+		t.Errorf("frame is %v", big)
+	}
+
+	// Two squares sharing an edge merge into a rectangle.
+	polys = Union(Shape{Contours: [][][2]float64{square(0, 0, 1, false), square(1, 0, 1, true)}})
+	if len(polys) != 1 || len(polys[0].Outer) != 4 || math.Abs(polys[0].Area()-2) > 1e-9 {
+		t.Errorf("adjacent squares are %v", polys)
+	}
+
+	// Squares that touch at a corner remain separate.
+	polys = Union(Shape{Contours: [][][2]float64{square(0, 0, 1, false), square(1, 1, 1, false)}})
+	if len(polys) != 2 || len(polys[0].Outer) != 4 || len(polys[1].Outer) != 4 {
+		t.Errorf("corner touching squares are %v", polys)
+	}
+}
+
+func TestStrokedTracks(t *testing.T) {
+	svg, err := svger.ParseSvg(`<svg>
+<g fill="none" stroke="black" stroke-width="2" stroke-linecap="round" stroke-linejoin="round">
+<path d="M0 0 L10 0 L10 10"/>
+<path d="M10 10 L0 10"/>
+</g>
+<circle cx="5" cy="0" r="3" fill="black"/>
+</svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	const tol = 0.001
+	segs, err := svg.ParseSegments(tol)
+	if err != nil {
+		t.Fatalf("ParseSegments failed: %v", err)
+	}
+	shapes := append(Shapes(segs), Shapes(svger.StrokeOutlines(segs, tol))...)
+	if len(shapes) != 3 {
+		t.Fatalf("got %d shapes, want 3: %v", len(shapes), shapes)
+	}
+	polys := Union(shapes...)
+	if len(polys) != 1 || len(polys[0].Holes) != 0 {
+		t.Fatalf("got %v, want one polygon without holes", polys)
+	}
+	// The union covers the points within 1 of the tracks, or
+	// within 3 of the center of the circle.
+	tracks := [][2][2]float64{{{0, 0}, {10, 0}}, {{10, 0}, {10, 10}}, {{10, 10}, {0, 10}}}
+	for x := -4.0; x <= 12; x += 0.1 {
+		for y := -4.0; y <= 12; y += 0.1 {
+			d := math.Hypot(x-5, y) - 2
+			for _, tr := range tracks {
+				d = math.Min(d, segmentDistance([2]float64{x, y}, tr[0], tr[1]))
+			}
+			if in := windingOf([2]float64{x, y}, polys) != 0; d < 1-0.01 && !in || d > 1+0.01 && in {
+				t.Errorf("(%g,%g) at distance %g is inside=%v", x, y, d, in)
+			}
+		}
+	}
+
+	out := Segments(polys, "black")
+	if len(out) != 1 || !out[0].Closed || out[0].Fill != "black" {
+		t.Errorf("Segments gave %v", out)
+	}
+
+	g := svg.Groups[0]
+	if polys, err := GroupShapes(g, tol); err != nil || len(polys) != 0 {
+		t.Errorf("open tracks have shapes %v (err=%v)", polys, err)
+	}
+}
+
+func TestGroupShapes(t *testing.T) {
+	svg, err := svger.ParseSvg(`<svg><g fill-rule="nonzero">
+<path d="M0 0 H4 V4 H0 Z"/>
+<path d="M2 2 V6 H6 V2 Z"/>
+<path d="M10 0 H12 V2 H10 Z" fill="none" stroke="black"/>
+<path d="M20 0 H26 V6 H20 Z M22 2 H24 V4 H22 Z" fill-rule="evenodd"/>
+</g></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	segs, err := svg.ParseSegments(0.01)
+	if err != nil {
+		t.Fatalf("ParseSegments failed: %v", err)
+	}
+	if shapes := Shapes(segs); len(shapes) != 3 || shapes[2].Rule != EvenOdd || len(shapes[2].Contours) != 2 {
+		t.Errorf("got shapes %v", shapes)
+	}
+	polys, err := GroupShapes(svg.Groups[0], 0.01)
+	if err != nil {
+		t.Fatalf("GroupShapes failed: %v", err)
+	}
+	// The opposite pads overlap without cancelling, and the evenodd
+	// square keeps its hole.
+	if len(polys) != 2 || math.Abs(totalArea(polys)-(28+32)) > 1e-9 {
+		t.Errorf("got %v, with area %g, want 2 polygons with area 60", polys, totalArea(polys))
+	}
+	for _, p := range polys {
+		if n := len(p.Holes); p.Area() > 30 && n != 1 || p.Area() < 30 && n != 0 {
+			t.Errorf("polygon %v has %d holes", p, n)
+		}
+	}
+}
+
+// segmentDistance returns the distance of p from the line segment from
+// a to b.
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
+
+// windingOf returns the winding number of the contours of polys about
+// p.
+func windingOf(p [2]float64, polys []Polygon) int {
+	w := 0
+	for _, c := range Segments(polys, "") {
+		for i, a := range c.Points {
+			b := c.Points[(i+1)%len(c.Points)]
+			o := orient(point(a), point(b), point(p))
+			if a[1] <= p[1] && b[1] > p[1] && o > 0 {
+				w++
+			} else if a[1] > p[1] && b[1] <= p[1] && o < 0 {
+				w--
+			}
+		}
+	}
+	return w
+}
@@ -0,0 +1,296 @@
+package svger
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// styleNode describes an element, and its ancestry, for the purpose
+// of matching it against stylesheet selectors.
+type styleNode struct {
+	name   string
+	attrs  []xml.Attr
+	parent *styleNode
+	// viewport is the size of the viewport that percentage
+	// lengths of the element are relative to.
+	viewport Tuple
+}
+
+// attr returns the value of the named attribute of the node.
+func (n *styleNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// cssCompound is a compound selector, such as "path.st0#outline",
+// along with the combinator that relates it to the compound selector
+// to its left: ' ' for a descendant and '>' for a child.
+type cssCompound struct {
+	combinator byte
+	name       string
+	id         string
+	classes    []string
+}
+
+// matches confirms the compound selector matches node n without
+// regard to its ancestors.
+func (c *cssCompound) matches(n *styleNode) bool {
+	if c.name != "" && c.name != "*" && c.name != n.name {
+		return false
+	}
+	if c.id != "" && c.id != n.attr("id") {
+		return false
+	}
+	if len(c.classes) == 0 {
+		return true
+	}
+	classes := strings.Fields(n.attr("class"))
+	for _, want := range c.classes {
+		found := false
+		for _, class := range classes {
+			if class == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// cssDeclaration is a single property value pair of a rule.
+type cssDeclaration struct {
+	prop, val string
+	important bool
+}
+
+// cssRule associates a single (ungrouped) selector with the
+// declarations of the rule it was found in.
+type cssRule struct {
+	selector []cssCompound
+	// specificity counts the id, class and type components of
+	// the selector.
+	specificity [3]int
+	// order is the position of the rule in the document.
+	order int
+	decls []cssDeclaration
+}
+
+// matches confirms the rule applies to node n.
+func (r *cssRule) matches(n *styleNode) bool {
+	return matchSelector(r.selector, len(r.selector)-1, n)
+}
+
+// matchSelector matches the compound selectors sel[:i+1] from right
+// to left against n and its ancestors.
+func matchSelector(sel []cssCompound, i int, n *styleNode) bool {
+	if !sel[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if sel[i].combinator == '>' {
+		return n.parent != nil && matchSelector(sel, i-1, n.parent)
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		if matchSelector(sel, i-1, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// styleSheet holds the rules of all of the style elements of an SVG
+// document.
+type styleSheet []*cssRule
+
+// matching returns the rules that apply to node n in increasing
+// order of precedence.
+func (ss styleSheet) matching(n *styleNode) []*cssRule {
+	var rules []*cssRule
+	for _, r := range ss {
+		if r.matches(n) {
+			rules = append(rules, r)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.specificity != b.specificity {
+			for k := range a.specificity {
+				if a.specificity[k] != b.specificity[k] {
+					return a.specificity[k] < b.specificity[k]
+				}
+			}
+		}
+		return a.order < b.order
+	})
+	return rules
+}
+
+// parseCSS parses the content of a style element and appends its
+// rules to the stylesheet. At-rules and selectors that use
+// unsupported features, such as attribute selectors and
+// pseudo-classes, are ignored.
+func (ss styleSheet) parseCSS(text string) styleSheet {
+	text = stripCSSComments(text)
+	for {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return ss
+		}
+		open := strings.IndexByte(text, '{')
+		if text[0] == '@' {
+			// Skip a statement at-rule, or a block at-rule
+			// along with its nested blocks.
+			if semi := strings.IndexByte(text, ';'); semi >= 0 && (open < 0 || semi < open) {
+				text = text[semi+1:]
+				continue
+			}
+			if open < 0 {
+				return ss
+			}
+			depth := 0
+			end := len(text)
+			for i := open; i < len(text); i++ {
+				if text[i] == '{' {
+					depth++
+				} else if text[i] == '}' {
+					if depth--; depth == 0 {
+						end = i + 1
+						break
+					}
+				}
+			}
+			text = text[end:]
+			continue
+		}
+		if open < 0 {
+			return ss
+		}
+		end := strings.IndexByte(text[open:], '}')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += open
+		}
+		decls := parseCSSDeclarations(text[open+1 : end])
+		for _, sel := range strings.Split(text[:open], ",") {
+			r := parseCSSSelector(sel)
+			if r == nil {
+				continue
+			}
+			r.order = len(ss)
+			r.decls = decls
+			ss = append(ss, r)
+		}
+		if end == len(text) {
+			return ss
+		}
+		text = text[end+1:]
+	}
+}
+
+// stripCSSComments removes all /* ... */ comments from text.
+func stripCSSComments(text string) string {
+	for {
+		start := strings.Index(text, "/*")
+		if start < 0 {
+			return text
+		}
+		end := strings.Index(text[start+2:], "*/")
+		if end < 0 {
+			return text[:start]
+		}
+		text = text[:start] + " " + text[start+2+end+2:]
+	}
+}
+
+// parseCSSDeclarations parses the body of a rule.
+func parseCSSDeclarations(body string) []cssDeclaration {
+	var decls []cssDeclaration
+	for _, decl := range strings.Split(body, ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		d := cssDeclaration{
+			prop: strings.TrimSpace(kv[0]),
+			val:  strings.TrimSpace(kv[1]),
+		}
+		if i := strings.Index(d.val, "!"); i >= 0 && strings.TrimSpace(d.val[i+1:]) == "important" {
+			d.val = strings.TrimSpace(d.val[:i])
+			d.important = true
+		}
+		decls = append(decls, d)
+	}
+	return decls
+}
+
+// parseCSSSelector parses a single selector, returning nil if it is
+// not supported.
+func parseCSSSelector(sel string) *cssRule {
+	r := &cssRule{}
+	combinator := byte(' ')
+	for _, word := range strings.Fields(strings.ReplaceAll(sel, ">", " > ")) {
+		if word == ">" {
+			if len(r.selector) == 0 || combinator == '>' {
+				return nil
+			}
+			combinator = '>'
+			continue
+		}
+		c := cssCompound{combinator: combinator}
+		combinator = ' '
+		rest := word
+		for i := 0; ; i++ {
+			end := strings.IndexAny(rest[1:], ".#") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			part := rest[:end]
+			switch {
+			case part[0] == '#' && len(part) > 1:
+				c.id = part[1:]
+				r.specificity[0]++
+			case part[0] == '.' && len(part) > 1:
+				c.classes = append(c.classes, part[1:])
+				r.specificity[1]++
+			case i == 0 && part == "*":
+				c.name = part
+			case i == 0 && isCSSIdent(part):
+				c.name = part
+				r.specificity[2]++
+			default:
+				return nil
+			}
+			if rest = rest[end:]; rest == "" {
+				break
+			}
+		}
+		r.selector = append(r.selector, c)
+	}
+	if len(r.selector) == 0 || combinator == '>' {
+		return nil
+	}
+	return r
+}
+
+// isCSSIdent confirms s is a plain identifier.
+func isCSSIdent(s string) bool {
+	for _, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -30,16 +30,21 @@ type CurvePoints struct {
 // The struct contains all necessary fields but only the ones needed (as
 // indicated byt the InstructionType) will be non-nil.
 type DrawingInstruction struct {
-	Kind           InstructionType
-	Error          error
-	M              *Tuple
-	CurvePoints    *CurvePoints
-	Radius         *float64
-	StrokeWidth    *float64
-	Fill           *string
-	Stroke         *string
-	StrokeLineCap  *string
-	StrokeLineJoin *string
+	Kind  InstructionType
+	Error error
+	// M is the point moved or drawn to. For a CloseInstruction
+	// it is the start of the subpath being closed, which becomes
+	// the current point.
+	M                *Tuple
+	CurvePoints      *CurvePoints
+	Radius           *float64
+	StrokeWidth      *float64
+	Fill             *string
+	FillRule         *string
+	Stroke           *string
+	StrokeLineCap    *string
+	StrokeLineJoin   *string
+	StrokeMiterLimit *float64
 }
 
 // DrawingInstructionParser allow getting segments and drawing
@@ -1,19 +1,92 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // Ellipse is an SVG ellipse XML element
 type Ellipse struct {
-	ID        string `xml:"id,attr"`
-	Transform string `xml:"transform,attr"`
-	Style     string `xml:"style,attr"`
-	Cx        string `xml:"cx,attr"`
-	Cy        string `xml:"cy,attr"`
-	Rx        string `xml:"rx,attr"`
-	Ry        string `xml:"ry,attr"`
-
-	transform mtransform.Transform
-	group     *Group
+	ID          string  `xml:"id,attr"`
+	Class       string  `xml:"class,attr"`
+	Transform   string  `xml:"transform,attr"`
+	Style       string  `xml:"style,attr"`
+	Cx          float64 `xml:"-"`
+	Cy          float64 `xml:"-"`
+	Rx          float64 `xml:"-"`
+	Ry          float64 `xml:"-"`
+	Fill        string  `xml:"fill,attr"`
+	Stroke      string  `xml:"stroke,attr"`
+	StrokeWidth float64 `xml:"-"`
+
+	group *Group
+	attrs []xml.Attr
+}
+
+// ParseDrawingInstructions implements the DrawingInstructionParser
+// interface. The ellipse is drawn with four cubic Bézier curves so
+// it is faithfully rendered after any transformation.
+func (e *Ellipse) ParseDrawingInstructions() chan *DrawingInstruction {
+	if e.group == nil {
+		e.group = new(Group)
+		temp := mt.Identity()
+		e.group.Transform = &temp
+	}
+	cs := e.group.elementStyle("ellipse", e.attrs)
+	pdp := newPathDParse()
+	ellipseTransform, terr := parseTransform(e.Transform)
+	pdp.transform = mt.MultiplyTransforms(pdp.transform, *e.group.Transform)
+	pdp.transform = mt.MultiplyTransforms(pdp.transform, ellipseTransform)
+
+	draw := make(chan *DrawingInstruction)
+	go func() {
+		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
+
+		// A zero radius disables rendering of the element.
+		if e.Rx <= 0 || e.Ry <= 0 {
+			return
+		}
+
+		x, y := pdp.transform.Apply(e.Cx+e.Rx, e.Cy)
+		draw <- &DrawingInstruction{
+			Kind: MoveInstruction,
+			M:    &Tuple{x, y},
+		}
+		for _, c := range ellipseCubics(e.Cx, e.Cy, e.Rx, e.Ry) {
+			draw <- curveInstruction(&pdp.transform, c)
+		}
+		draw <- &DrawingInstruction{
+			Kind: CloseInstruction,
+			M:    &Tuple{x, y},
+		}
+
+		draw <- cs.paintInstruction(e.group.strokeScale(&pdp.transform))
+	}()
+	return draw
+}
+
+// updateStyle sets the exported style fields of the ellipse to their
+// computed values.
+func (e *Ellipse) updateStyle() {
+	cs := e.group.elementStyle("ellipse", e.attrs)
+	e.Fill, e.Stroke, e.StrokeWidth = cs.Fill, cs.Stroke, cs.StrokeWidth
+}
+
+// setLengths resolves the geometry attributes of the ellipse within a
+// viewport of size vp.
+func (e *Ellipse) setLengths(vp Tuple) {
+	setLengths(e.attrs, vp, map[string]*float64{
+		"cx": &e.Cx,
+		"cy": &e.Cy,
+		"rx": &e.Rx,
+		"ry": &e.Ry,
+	})
 }
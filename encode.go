@@ -0,0 +1,477 @@
+package svger
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
+)
+
+// Namespaces commonly used by SVG documents.
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+	xmlNamespace   = "http://www.w3.org/XML/1998/namespace"
+)
+
+// EncodeOptions control how Encode writes an image.
+type EncodeOptions struct {
+	// BakeTransforms causes each drawn element to be written as
+	// one or more path elements whose coordinates have all of the
+	// transforms of the element, its groups and any use element
+	// applied. The computed paint of each path is written as
+	// presentation attributes, so no stylesheet, style, class or
+	// transform attributes are written, and the referenced content
+	// of use elements is written in their place.
+	BakeTransforms bool
+}
+
+// Encode writes the image as SVG XML to w. A nil opts selects the
+// default options.
+//
+// Groups and elements are written with their ids, classes, styles,
+// transforms and other attributes as they were parsed. The geometry
+// attributes are written from the exported fields, so edits to them
+// are reflected in the output. Elements that svger doesn't decode are
+// not written.
+func (s *Svg) Encode(w io.Writer, opts *EncodeOptions) error {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+	e := &encoder{
+		w:        bufio.NewWriter(w),
+		opts:     opts,
+		prefixes: map[string]string{xlinkNamespace: "xlink", xmlNamespace: "xml"},
+	}
+	for _, a := range s.attrs {
+		if a.Name.Space == "xmlns" {
+			e.prefixes[a.Value] = a.Name.Local
+		}
+	}
+	if opts.BakeTransforms {
+		// Coordinates are written in the user space of the svg
+		// element, which Transform maps to the output space.
+		e.unbake = mt.Identity()
+		if s.Transform != nil {
+			inv, err := s.Transform.Invert()
+			if err != nil {
+				return fmt.Errorf("unable to bake transforms: %v", err)
+			}
+			e.unbake = inv
+		}
+	}
+
+	attrs := editAttrs(s.attrs)
+	attrs.setString("width", s.Width)
+	attrs.setString("height", s.Height)
+	attrs.setString("viewBox", s.ViewBox)
+	attrs.setString("preserveAspectRatio", s.PreserveAspectRatio)
+	if attrs.index("xmlns") < 0 {
+		attrs = append(attrList{{Name: xml.Name{Local: "xmlns"}, Value: svgNamespace}}, attrs...)
+	}
+	e.start("svg", attrs)
+	if s.Title != "" {
+		e.start("title", nil)
+		e.text(s.Title)
+		e.end("title")
+	}
+	if !opts.BakeTransforms {
+		for _, style := range s.styles {
+			e.start("style", nil)
+			e.text(style)
+			e.end("style")
+		}
+		for _, d := range s.defs {
+			e.element(d)
+		}
+	}
+	for _, el := range s.children() {
+		e.element(el)
+	}
+	e.end("svg")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// encoder holds the state of writing an image.
+type encoder struct {
+	w     *bufio.Writer
+	opts  *EncodeOptions
+	depth int
+	// prefixes maps namespaces to the prefixes declared for them.
+	prefixes map[string]string
+	// unbake maps baked coordinates to the user space of the svg
+	// element.
+	unbake mt.Transform
+	err    error
+}
+
+// start writes a start tag on a new line.
+func (e *encoder) start(name string, attrs attrList) {
+	e.tag(name, attrs, false)
+}
+
+// empty writes an empty element tag on a new line.
+func (e *encoder) empty(name string, attrs attrList) {
+	e.tag(name, attrs, true)
+}
+
+// tag writes a start tag, or an empty element tag if empty is true.
+func (e *encoder) tag(name string, attrs attrList, empty bool) {
+	e.printf("%s<%s", strings.Repeat("  ", e.depth), name)
+	for _, a := range attrs {
+		var name string
+		switch a.Name.Space {
+		case "":
+			name = a.Name.Local
+		case "xmlns":
+			name = "xmlns:" + a.Name.Local
+		default:
+			prefix, ok := e.prefixes[a.Name.Space]
+			if !ok {
+				// An attribute in an undeclared namespace
+				// can't be written.
+				continue
+			}
+			name = prefix + ":" + a.Name.Local
+		}
+		e.printf(" %s=\"", name)
+		e.escape(a.Value)
+		e.printf("\"")
+	}
+	if empty {
+		e.printf("/>\n")
+		return
+	}
+	e.printf(">\n")
+	e.depth++
+}
+
+// end writes an end tag on a new line.
+func (e *encoder) end(name string) {
+	e.depth--
+	e.printf("%s</%s>\n", strings.Repeat("  ", e.depth), name)
+}
+
+// text writes character data.
+func (e *encoder) text(s string) {
+	e.escape(s)
+	e.printf("\n")
+}
+
+func (e *encoder) escape(s string) {
+	if e.err == nil {
+		e.err = xml.EscapeText(e.w, []byte(s))
+	}
+}
+
+func (e *encoder) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// element writes a group or drawn element.
+func (e *encoder) element(el DrawingInstructionParser) {
+	if g, ok := el.(*Group); ok {
+		e.group(g)
+		return
+	}
+	if e.opts.BakeTransforms {
+		e.baked(el)
+		return
+	}
+	name, attrs := elementAttrs(el)
+	if name == "" {
+		return
+	}
+	e.empty(name, attrs)
+}
+
+// group writes a group and its content.
+func (e *encoder) group(g *Group) {
+	name := "g"
+	if g.tag != "" {
+		name = g.tag
+	}
+	var attrs attrList
+	if e.opts.BakeTransforms {
+		if isDefinition(xml.StartElement{Name: xml.Name{Local: name}}) {
+			return
+		}
+		// The viewport of a nested svg element is baked into
+		// the coordinates of its content.
+		name = "g"
+		attrs.setString("id", g.ID)
+	} else {
+		attrs = editAttrs(g.attrs)
+		attrs.setString("id", g.ID)
+		attrs.setString("class", g.Class)
+		attrs.setString("transform", g.TransformString)
+	}
+	e.start(name, attrs)
+	if !e.opts.BakeTransforms {
+		for _, d := range g.defs {
+			e.group(d)
+		}
+	}
+	for _, el := range g.Elements {
+		e.element(el)
+	}
+	e.end(name)
+}
+
+// baked writes the paths drawn by an element. An element, such as a
+// use element, that draws more than one shape is written as a group
+// of paths.
+func (e *encoder) baked(el DrawingInstructionParser) {
+	var paths []attrList
+	// Coordinates are written in the user space of the svg element.
+	d := &pathWriter{precision: -1, xf: &e.unbake}
+	for di := range el.ParseDrawingInstructions() {
+		if di.Kind == ErrorInstruction {
+			if e.err == nil {
+				e.err = di.Error
+			}
+			continue
+		}
+		if di.Kind != PaintInstruction {
+			d.instruction(di)
+			continue
+		}
+		if d.String() != "" {
+			attrs := attrList{{Name: xml.Name{Local: "d"}, Value: d.String()}}
+			paths = append(paths, append(attrs, e.paintAttrs(di)...))
+		}
+		d.reset()
+	}
+	id := elementID(el)
+	if len(paths) == 1 {
+		if id != "" {
+			paths[0] = append(attrList{{Name: xml.Name{Local: "id"}, Value: id}}, paths[0]...)
+		}
+		e.empty("path", paths[0])
+		return
+	}
+	if len(paths) == 0 {
+		return
+	}
+	var attrs attrList
+	attrs.setString("id", id)
+	e.start("g", attrs)
+	for _, p := range paths {
+		e.empty("path", p)
+	}
+	e.end("g")
+}
+
+// paintAttrs returns the presentation attributes of a baked path with
+// the paint described by a PaintInstruction. They are all written, so
+// none are inherited from the svg element.
+func (e *encoder) paintAttrs(di *DrawingInstruction) attrList {
+	var attrs attrList
+	if di.Fill != nil {
+		attrs.setString("fill", *di.Fill)
+	}
+	if di.FillRule != nil {
+		attrs.setString("fill-rule", *di.FillRule)
+	}
+	if di.Stroke != nil {
+		attrs.setString("stroke", *di.Stroke)
+	}
+	if di.Stroke == nil || *di.Stroke == "none" {
+		return attrs
+	}
+	if di.StrokeWidth != nil {
+		attrs.setString("stroke-width", formatNumber(*di.StrokeWidth*transformScale(&e.unbake)))
+	}
+	if di.StrokeLineCap != nil {
+		attrs.setString("stroke-linecap", *di.StrokeLineCap)
+	}
+	if di.StrokeLineJoin != nil {
+		attrs.setString("stroke-linejoin", *di.StrokeLineJoin)
+	}
+	if di.StrokeMiterLimit != nil {
+		attrs.setString("stroke-miterlimit", formatNumber(*di.StrokeMiterLimit))
+	}
+	return attrs
+}
+
+// attrList holds the XML attributes of an element being written.
+type attrList []xml.Attr
+
+// editAttrs returns a copy of attrs that can be edited.
+func editAttrs(attrs []xml.Attr) attrList {
+	return append(attrList(nil), attrs...)
+}
+
+// index returns the position of the un-namespaced attribute name, or
+// -1 if there is no such attribute.
+func (a attrList) index(name string) int {
+	for i, attr := range a {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// setString sets the value of attribute name. An empty value removes
+// the attribute.
+func (a *attrList) setString(name, val string) {
+	i := a.index(name)
+	switch {
+	case val == "" && i >= 0:
+		*a = append((*a)[:i], (*a)[i+1:]...)
+	case val == "":
+	case i >= 0:
+		(*a)[i].Value = val
+	default:
+		*a = append(*a, xml.Attr{Name: xml.Name{Local: name}, Value: val})
+	}
+}
+
+// setNumber sets the value of attribute name, unless its existing
+// value already represents val. A missing attribute is only added if
+// val is not zero, its default.
+func (a *attrList) setNumber(name string, val float64) {
+	i := a.index(name)
+	if i < 0 {
+		if val != 0 {
+			a.setString(name, formatNumber(val))
+		}
+		return
+	}
+	if f, err := strconv.ParseFloat(strings.TrimSpace((*a)[i].Value), 64); err == nil && f == val {
+		return
+	}
+	a.setString(name, formatNumber(val))
+}
+
+// setOptional sets the value of attribute name to *val, or removes the
+// attribute if val is nil.
+func (a *attrList) setOptional(name string, val *float64) {
+	if val == nil {
+		a.setString(name, "")
+		return
+	}
+	if *val == 0 && a.index(name) < 0 {
+		a.setString(name, "0")
+		return
+	}
+	a.setNumber(name, *val)
+}
+
+// setHref sets the reference of a use element, in whichever of the
+// href or xlink:href attributes it was parsed from.
+func (a *attrList) setHref(val string) {
+	for i, attr := range *a {
+		if attr.Name.Local == "href" && attr.Name.Space == xlinkNamespace && a.index("href") < 0 {
+			(*a)[i].Value = val
+			return
+		}
+	}
+	a.setString("href", val)
+}
+
+// formatNumber formats a number for an SVG attribute.
+func formatNumber(f float64) string {
+	if f == 0 {
+		// Avoid writing negative zero.
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// elementID returns the id of a drawn element.
+func elementID(el DrawingInstructionParser) string {
+	switch el := el.(type) {
+	case *Path:
+		return el.ID
+	case *Rect:
+		return el.ID
+	case *Circle:
+		return el.ID
+	case *Ellipse:
+		return el.ID
+	case *Line:
+		return el.ID
+	case *Polygon:
+		return el.ID
+	case *PolyLine:
+		return el.ID
+	case *Use:
+		return el.ID
+	}
+	return ""
+}
+
+// elementAttrs returns the XML element name and attributes of a
+// drawn element. The name is empty for unknown element types.
+func elementAttrs(el DrawingInstructionParser) (string, attrList) {
+	var name string
+	var attrs attrList
+	var id, class, style, transform string
+	switch el := el.(type) {
+	case *Path:
+		name, attrs = "path", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.TransformString
+		attrs.setString("d", el.D)
+	case *Rect:
+		name, attrs = "rect", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setNumber("x", el.X)
+		attrs.setNumber("y", el.Y)
+		attrs.setNumber("width", el.Width)
+		attrs.setNumber("height", el.Height)
+		attrs.setOptional("rx", el.Rx)
+		attrs.setOptional("ry", el.Ry)
+	case *Circle:
+		name, attrs = "circle", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setNumber("cx", el.Cx)
+		attrs.setNumber("cy", el.Cy)
+		attrs.setNumber("r", el.Radius)
+	case *Ellipse:
+		name, attrs = "ellipse", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setNumber("cx", el.Cx)
+		attrs.setNumber("cy", el.Cy)
+		attrs.setNumber("rx", el.Rx)
+		attrs.setNumber("ry", el.Ry)
+	case *Line:
+		name, attrs = "line", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setNumber("x1", el.X1)
+		attrs.setNumber("y1", el.Y1)
+		attrs.setNumber("x2", el.X2)
+		attrs.setNumber("y2", el.Y2)
+	case *Polygon:
+		name, attrs = "polygon", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setString("points", el.Points)
+	case *PolyLine:
+		name, attrs = "polyline", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setString("points", el.Points)
+	case *Use:
+		name, attrs = "use", editAttrs(el.attrs)
+		id, class, style, transform = el.ID, el.Class, el.Style, el.Transform
+		attrs.setHref(el.href())
+		attrs.setNumber("x", el.X)
+		attrs.setNumber("y", el.Y)
+	default:
+		return "", nil
+	}
+	attrs.setString("id", id)
+	attrs.setString("class", class)
+	attrs.setString("style", style)
+	attrs.setString("transform", transform)
+	return name, attrs
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -13,6 +14,11 @@ import (
 var (
 	src   = flag.String("src", "/dev/stdin", "source SVG file")
 	debug = flag.Bool("debug", false, "extra debugging output")
+	units = flag.String("units", "", "if set, map the image onto its width and height in these units (px, mm, in, ...)")
+	flat  = flag.Float64("flatten", 0, "if positive, replace curves with lines deviating by at most this distance")
+	bake  = flag.Bool("bake", false, "write the SVG to stdout with all transforms baked into its paths")
+	paths = flag.Bool("paths", false, "write the path data of each painted shape to stdout")
+	prec  = flag.Int("precision", -1, "decimal places of --paths coordinates, or -1 for exact values")
 )
 
 // read an SVG or fail the program.
@@ -27,7 +33,16 @@ func readSVG() *svger.Svg {
 	}
 	defer f.Close()
 
-	s, err := svger.ParseSvgFromReader(f, *src, 1)
+	var s *svger.Svg
+	if *units == "" {
+		s, err = svger.ParseSvgFromReader(f, *src, 1)
+	} else {
+		u, uerr := svger.ParseUnit(*units)
+		if uerr != nil {
+			log.Fatalf("bad --units: %v", uerr)
+		}
+		s, err = svger.ParseSvgFromReaderInUnits(f, *src, u)
+	}
 	if err != nil {
 		log.Fatalf("failed to parse %q: %v", *src, err)
 	}
@@ -38,6 +53,9 @@ func readSVG() *svger.Svg {
 // svger.DrawingInstructions.
 func decodeSVG(s *svger.Svg) (dis []*svger.DrawingInstruction, err error) {
 	ins := s.ParseDrawingInstructions()
+	if *flat > 0 {
+		ins = svger.Flatten(ins, *flat)
+	}
 	for {
 		i, ok := <-ins
 		if !ok {
@@ -66,6 +84,9 @@ func decodeSVG(s *svger.Svg) (dis []*svger.DrawingInstruction, err error) {
 		if i.Fill != nil {
 			log.Printf("    Fill=%v", *i.Fill)
 		}
+		if i.FillRule != nil {
+			log.Printf("    FillRule=%v", *i.FillRule)
+		}
 		if i.Stroke != nil {
 			log.Printf("    Stroke=%v", *i.Stroke)
 		}
@@ -75,6 +96,9 @@ func decodeSVG(s *svger.Svg) (dis []*svger.DrawingInstruction, err error) {
 		if i.StrokeLineJoin != nil {
 			log.Printf("    StrokeLineJoin=%v", *i.StrokeLineJoin)
 		}
+		if i.StrokeMiterLimit != nil {
+			log.Printf("    StrokeMiterLimit=%v", *i.StrokeMiterLimit)
+		}
 	}
 }
 
@@ -87,6 +111,28 @@ func main() {
 		log.Printf("SVG: %#v", s)
 	}
 
+	if *bake {
+		if err := s.Encode(os.Stdout, &svger.EncodeOptions{BakeTransforms: true}); err != nil {
+			log.Fatalf("failed to encode SVG: %v", err)
+		}
+		return
+	}
+
+	if *paths {
+		ins := s.ParseDrawingInstructions()
+		if *flat > 0 {
+			ins = svger.Flatten(ins, *flat)
+		}
+		pds, err := svger.EncodePathData(ins, *prec)
+		if err != nil {
+			log.Fatalf("failed to encode path data: %v", err)
+		}
+		for _, pd := range pds {
+			fmt.Println(pd.D)
+		}
+		return
+	}
+
 	dis, err := decodeSVG(s)
 	if err != nil {
 		log.Fatalf("failed to fully decode SVG: %v", err)
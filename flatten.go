@@ -0,0 +1,64 @@
+package svger
+
+import "fmt"
+
+// Flatten relays the drawing instructions read from in, replacing
+// each CurveInstruction by LineInstructions and each
+// CircleInstruction by the Move, Line and Close instructions of a
+// polygon. Since arcs, ellipses and rounded rects are drawn with
+// curves, the output only contains straight lines. No point of the
+// original shapes is further than tolerance from the lines. The
+// tolerance is in the units of the instruction coordinates, so for an
+// image parsed with ParseSvgInUnits(..., Mm), a tolerance of 0.01 is
+// 10 microns.
+//
+// A tolerance that isn't positive yields a single ErrorInstruction.
+func Flatten(in <-chan *DrawingInstruction, tolerance float64) chan *DrawingInstruction {
+	out := make(chan *DrawingInstruction, 100)
+	go func() {
+		defer close(out)
+		if !(tolerance > 0) {
+			out <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: fmt.Errorf("flattening tolerance must be positive, got %v", tolerance),
+			}
+			// Drain the input so its producer can finish.
+			for range in {
+			}
+			return
+		}
+
+		var current Tuple
+		for di := range in {
+			switch di.Kind {
+			case CurveInstruction:
+				cp := di.CurvePoints
+				for _, pt := range FlattenCubic(current, *cp.C1, *cp.C2, *cp.T, tolerance) {
+					pt := pt
+					out <- &DrawingInstruction{Kind: LineInstruction, M: &pt}
+				}
+				current = *cp.T
+			case CircleInstruction:
+				pts := FlattenCircle(*di.M, *di.Radius, tolerance)
+				for i, pt := range pts {
+					pt := pt
+					k := LineInstruction
+					switch i {
+					case 0:
+						k = MoveInstruction
+					case len(pts) - 1:
+						k = CloseInstruction
+					}
+					out <- &DrawingInstruction{Kind: k, M: &pt}
+				}
+				current = pts[0]
+			default:
+				if di.M != nil {
+					current = *di.M
+				}
+				out <- di
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,269 @@
+// Package gcode converts svger drawing instructions into G-code for
+// laser cutters, such as the Snapmaker 2.
+package gcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"zappem.net/pub/graphics/svger"
+)
+
+// Units selects the unit mode of the G-code.
+type Units int
+
+const (
+	// Millimeters selects G21. The drawing instruction coordinates
+	// must be in millimeters, as parsed with
+	// svger.ParseSvgInUnits(..., svger.Mm).
+	Millimeters Units = iota
+	// Inches selects G20. The drawing instruction coordinates must
+	// be in inches.
+	Inches
+)
+
+// Pass describes one traversal of every shape by the laser.
+type Pass struct {
+	// Power is the S value of the laser while cutting. Its scale
+	// depends on the machine, for example 0-255 or 0-1000.
+	Power float64
+	// Feed is the F value, the cutting speed in units per minute.
+	Feed float64
+}
+
+// Options control the G-code that Write generates.
+type Options struct {
+	// Header and Footer are lines written before and after the
+	// generated G-code, such as homing commands.
+	Header []string
+	Footer []string
+	// Units selects the unit mode.
+	Units Units
+	// Origin is the point of the drawing that is written as the
+	// machine origin.
+	Origin svger.Tuple
+	// FlipY negates y coordinates, since SVG y coordinates
+	// increase downwards and machine ones upwards.
+	FlipY bool
+	// Passes are the passes made over all of the shapes, in
+	// order. There must be at least one.
+	Passes []Pass
+	// Arcs causes circles to be cut with G2 or G3 arcs instead of
+	// lines.
+	Arcs bool
+	// Tolerance is the furthest the cut lines may deviate from
+	// curves, and circles if Arcs is false. Zero selects 0.01.
+	Tolerance float64
+	// Decimals is the number of decimal places of coordinates.
+	// Zero selects 3, and a negative value whole numbers.
+	Decimals int
+	// LaserOn and LaserOff are the commands that switch the laser
+	// on, with the S value of the pass appended, and off. Empty
+	// values select M3 and M5.
+	LaserOn  string
+	LaserOff string
+}
+
+// shape is a polyline or a circle to be cut.
+type shape struct {
+	points []svger.Tuple
+	// radius is non-zero for a circle centered on points[0].
+	radius float64
+}
+
+// Write reads drawing instructions from in until it is closed and
+// writes G-code to w that cuts the outlines of the drawn shapes,
+// ignoring their paint. Curves are cut as straight lines (see
+// svger.Flatten). The laser is switched off for the G0 moves between
+// shapes. Nothing is written if the instructions include an error.
+//
+// The options must specify at least one pass.
+func Write(w io.Writer, in <-chan *svger.DrawingInstruction, opts *Options) error {
+	if opts == nil || len(opts.Passes) == 0 {
+		for range in {
+		}
+		return errors.New("no laser passes specified")
+	}
+	tolerance := opts.Tolerance
+	if tolerance == 0 {
+		tolerance = 0.01
+	} else if tolerance < 0 {
+		for range in {
+		}
+		return fmt.Errorf("flattening tolerance must be positive, got %v", tolerance)
+	}
+	shapes, err := collect(in, tolerance, opts.Arcs)
+	if err != nil {
+		return err
+	}
+
+	g := &writer{w: bufio.NewWriter(w), opts: opts, decimals: opts.Decimals}
+	switch {
+	case g.decimals == 0:
+		g.decimals = 3
+	case g.decimals < 0:
+		g.decimals = 0
+	}
+	on, off := opts.LaserOn, opts.LaserOff
+	if on == "" {
+		on = "M3"
+	}
+	if off == "" {
+		off = "M5"
+	}
+	// Circles are cut in the direction of increasing SVG angle, as
+	// svger.FlattenCircle does, which is clockwise once y is
+	// flipped.
+	arc := "G3"
+	if opts.FlipY {
+		arc = "G2"
+	}
+
+	for _, line := range opts.Header {
+		g.printf("%s\n", line)
+	}
+	if opts.Units == Inches {
+		g.printf("G20\n")
+	} else {
+		g.printf("G21\n")
+	}
+	g.printf("G90\n%s\n", off)
+	for i, pass := range opts.Passes {
+		g.printf("; pass %d\n", i+1)
+		for _, s := range shapes {
+			g.printf("G0%s\n", g.xy(s.points[0]))
+			g.printf("%s S%s\n", on, g.num(pass.Power))
+			feed := " F" + g.num(pass.Feed)
+			if s.radius != 0 {
+				// A full circle from (cx+r, cy), about its
+				// center.
+				g.printf("%s%s I%s J0%s\n", arc, g.xy(s.points[0]), g.num(-s.radius), feed)
+			} else {
+				for _, pt := range s.points[1:] {
+					g.printf("G1%s%s\n", g.xy(pt), feed)
+					feed = ""
+				}
+			}
+			g.printf("%s\n", off)
+		}
+	}
+	for _, line := range opts.Footer {
+		g.printf("%s\n", line)
+	}
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+// collect reads the shapes drawn by drawing instructions. Curves, and
+// circles unless arcs is true, are flattened to within tolerance.
+func collect(in <-chan *svger.DrawingInstruction, tolerance float64, arcs bool) ([]shape, error) {
+	var shapes []shape
+	var err error
+	var current *shape
+	var last, start svger.Tuple
+	finish := func() {
+		if current != nil && len(current.points) > 1 {
+			shapes = append(shapes, *current)
+		}
+		current = nil
+	}
+	lineTo := func(pt svger.Tuple) {
+		if current == nil {
+			current = &shape{points: []svger.Tuple{last}}
+		}
+		if pt != last {
+			current.points = append(current.points, pt)
+		}
+		last = pt
+	}
+	for di := range in {
+		if err != nil {
+			// Drain the remaining instructions.
+			continue
+		}
+		switch di.Kind {
+		case svger.ErrorInstruction:
+			err = di.Error
+			if err == nil {
+				err = errors.New("drawing instruction error")
+			}
+		case svger.MoveInstruction:
+			finish()
+			last, start = *di.M, *di.M
+		case svger.LineInstruction:
+			lineTo(*di.M)
+		case svger.CurveInstruction:
+			cp := di.CurvePoints
+			for _, pt := range svger.FlattenCubic(last, *cp.C1, *cp.C2, *cp.T, tolerance) {
+				lineTo(pt)
+			}
+		case svger.CloseInstruction:
+			if current != nil {
+				lineTo(start)
+			}
+			finish()
+			if di.M != nil {
+				start = *di.M
+			}
+			last = start
+		case svger.CircleInstruction:
+			finish()
+			c, r := *di.M, *di.Radius
+			if arcs {
+				shapes = append(shapes, shape{points: []svger.Tuple{{c[0] + r, c[1]}}, radius: r})
+			} else {
+				shapes = append(shapes, shape{points: svger.FlattenCircle(c, r, tolerance)})
+			}
+			last = svger.Tuple{c[0] + r, c[1]}
+			start = last
+		case svger.PaintInstruction:
+			finish()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	finish()
+	return shapes, nil
+}
+
+// writer holds the state of writing G-code.
+type writer struct {
+	w        *bufio.Writer
+	opts     *Options
+	decimals int
+	err      error
+}
+
+func (g *writer) printf(format string, args ...interface{}) {
+	if g.err == nil {
+		_, g.err = fmt.Fprintf(g.w, format, args...)
+	}
+}
+
+// num formats a number with at most the configured decimal places.
+func (g *writer) num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', g.decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// xy formats the machine coordinates of a point of the drawing.
+func (g *writer) xy(pt svger.Tuple) string {
+	x, y := pt[0]-g.opts.Origin[0], pt[1]-g.opts.Origin[1]
+	if g.opts.FlipY {
+		y = -y
+	}
+	return " X" + g.num(x) + " Y" + g.num(y)
+}
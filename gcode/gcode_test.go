@@ -0,0 +1,99 @@
+package gcode
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zappem.net/pub/graphics/svger"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const testSvg = `<svg width="20mm" height="10mm" viewBox="0 0 20 10">
+<rect x="1" y="1" width="4" height="3"/>
+<path d="M10 2 Q12 0 14 2 L14 4" fill="none" stroke="black"/>
+<circle cx="17" cy="7" r="2"/>
+</svg>`
+
+func TestWrite(t *testing.T) {
+	vs := []struct {
+		golden string
+		opts   Options
+	}{
+		{
+			golden: "default.gcode",
+			opts: Options{
+				Passes:    []Pass{{Power: 255, Feed: 300}},
+				Tolerance: 0.1,
+			},
+		},
+		{
+			golden: "arcs.gcode",
+			opts: Options{
+				Header:   []string{"; svger test", "G28"},
+				Footer:   []string{"G0 X0 Y0", "M2"},
+				Origin:   svger.Tuple{0, 10},
+				FlipY:    true,
+				Passes:   []Pass{{Power: 1000, Feed: 600}, {Power: 800, Feed: 300}},
+				Arcs:     true,
+				Decimals: 2,
+				LaserOn:  "M4",
+			},
+		},
+		{
+			golden: "whole.gcode",
+			opts: Options{
+				Passes:    []Pass{{Power: 255, Feed: 300}},
+				Arcs:      true,
+				Tolerance: 1,
+				Decimals:  -1,
+			},
+		},
+	}
+	for _, v := range vs {
+		s, err := svger.ParseSvgInUnits(testSvg, "test", svger.Mm)
+		if err != nil {
+			t.Fatalf("ParseSvgInUnits failed: %v", err)
+		}
+		var b bytes.Buffer
+		if err := Write(&b, s.ParseDrawingInstructions(), &v.opts); err != nil {
+			t.Errorf("%s: Write failed: %v", v.golden, err)
+			continue
+		}
+		path := filepath.Join("testdata", v.golden)
+		if *update {
+			if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+				t.Fatalf("failed to update %q: %v", path, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %q: %v", path, err)
+		}
+		if got := b.String(); got != string(want) {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", v.golden, got, want)
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	s, err := svger.ParseSvg(`<svg><path d="M0,0 L1"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	var b bytes.Buffer
+	if err := Write(&b, s.ParseDrawingInstructions(), &Options{Passes: []Pass{{Power: 1, Feed: 1}}}); err == nil {
+		t.Error("bad path data written without error")
+	}
+	if err := Write(&b, s.ParseDrawingInstructions(), &Options{}); err == nil || !strings.Contains(err.Error(), "pass") {
+		t.Errorf("no passes gave error %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("errors wrote %q", b.String())
+	}
+}
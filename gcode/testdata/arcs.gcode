@@ -0,0 +1,71 @@
+; svger test
+G28
+G21
+G90
+M5
+; pass 1
+G0 X1 Y9
+M4 S1000
+G1 X5 Y9 F600
+G1 X5 Y6
+G1 X1 Y6
+G1 X1 Y9
+M5
+G0 X10 Y8
+M4 S1000
+G1 X10.25 Y8.23 F600
+G1 X10.5 Y8.44
+G1 X10.75 Y8.61
+G1 X11 Y8.75
+G1 X11.25 Y8.86
+G1 X11.5 Y8.94
+G1 X11.75 Y8.98
+G1 X12 Y9
+G1 X12.25 Y8.98
+G1 X12.5 Y8.94
+G1 X12.75 Y8.86
+G1 X13 Y8.75
+G1 X13.25 Y8.61
+G1 X13.5 Y8.44
+G1 X13.75 Y8.23
+G1 X14 Y8
+G1 X14 Y6
+M5
+G0 X19 Y3
+M4 S1000
+G2 X19 Y3 I-2 J0 F600
+M5
+; pass 2
+G0 X1 Y9
+M4 S800
+G1 X5 Y9 F300
+G1 X5 Y6
+G1 X1 Y6
+G1 X1 Y9
+M5
+G0 X10 Y8
+M4 S800
+G1 X10.25 Y8.23 F300
+G1 X10.5 Y8.44
+G1 X10.75 Y8.61
+G1 X11 Y8.75
+G1 X11.25 Y8.86
+G1 X11.5 Y8.94
+G1 X11.75 Y8.98
+G1 X12 Y9
+G1 X12.25 Y8.98
+G1 X12.5 Y8.94
+G1 X12.75 Y8.86
+G1 X13 Y8.75
+G1 X13.25 Y8.61
+G1 X13.5 Y8.44
+G1 X13.75 Y8.23
+G1 X14 Y8
+G1 X14 Y6
+M5
+G0 X19 Y3
+M4 S800
+G2 X19 Y3 I-2 J0 F300
+M5
+G0 X0 Y0
+M2
@@ -0,0 +1,32 @@
+G21
+G90
+M5
+; pass 1
+G0 X1 Y1
+M3 S255
+G1 X5 Y1 F300
+G1 X5 Y4
+G1 X1 Y4
+G1 X1 Y1
+M5
+G0 X10 Y2
+M3 S255
+G1 X11 Y1.25 F300
+G1 X12 Y1
+G1 X13 Y1.25
+G1 X14 Y2
+G1 X14 Y4
+M5
+G0 X19 Y7
+M3 S255
+G1 X18.618 Y8.176 F300
+G1 X17.618 Y8.902
+G1 X16.382 Y8.902
+G1 X15.382 Y8.176
+G1 X15 Y7
+G1 X15.382 Y5.824
+G1 X16.382 Y5.098
+G1 X17.618 Y5.098
+G1 X18.618 Y5.824
+G1 X19 Y7
+M5
@@ -0,0 +1,21 @@
+G21
+G90
+M5
+; pass 1
+G0 X1 Y1
+M3 S255
+G1 X5 Y1 F300
+G1 X5 Y4
+G1 X1 Y4
+G1 X1 Y1
+M5
+G0 X10 Y2
+M3 S255
+G1 X12 Y1 F300
+G1 X14 Y2
+G1 X14 Y4
+M5
+G0 X19 Y7
+M3 S255
+G3 X19 Y7 I-2 J0 F300
+M5
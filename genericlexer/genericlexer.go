@@ -29,6 +29,8 @@ func (i Item) String() string {
 		return s + fmt.Sprintf("Word \"%s\"", i.Value)
 	case ItemNumber:
 		return s + fmt.Sprint("Number ", i.Value)
+	case ItemFlag:
+		return s + fmt.Sprint("Flag ", i.Value)
 	case ItemWSP:
 		return s + "WSP"
 	default:
@@ -45,6 +47,12 @@ type Lexer struct {
 	Items     chan Item
 	buffer    [3]Item
 	peekcount int
+	// arcs enables the recognition of elliptical arc flags, see
+	// LexPath.
+	arcs bool
+	// arcarg is the index of the next argument of an elliptical
+	// arc command, or -1 outside of one.
+	arcarg int
 }
 
 type ItemType int
@@ -66,9 +74,26 @@ type stateFn func(*Lexer) stateFn
 
 func Lex(name, input string) (*Lexer, chan Item) {
 	l := &Lexer{
-		name:  name,
-		input: input,
-		Items: make(chan Item),
+		name:   name,
+		input:  input,
+		Items:  make(chan Item),
+		arcarg: -1,
+	}
+	go l.run() // Concurrently run state machine.
+	return l, l.Items
+}
+
+// LexPath is like Lex, but scans SVG path data. The large-arc and
+// sweep flags (arguments 3 and 4) of an elliptical arc command are
+// emitted as single character ItemFlag tokens, since path data need
+// not separate them from what follows.
+func LexPath(name, input string) (*Lexer, chan Item) {
+	l := &Lexer{
+		name:   name,
+		input:  input,
+		Items:  make(chan Item),
+		arcs:   true,
+		arcarg: -1,
 	}
 	go l.run() // Concurrently run state machine.
 	return l, l.Items
@@ -141,26 +166,59 @@ func (l *Lexer) PeekItem() Item {
 	return l.buffer[0]
 }
 
+// lexNumber scans a number according to the SVG number grammar:
+//
+//	sign? (digits ("." digits?)? | "." digits) (("e"|"E") sign? digits)?
+//
+// Scanning stops at the first character that can't extend the
+// number, so compact sequences like ".5.5" and "1-2" are each split
+// into two numbers.
 func lexNumber(l *Lexer) stateFn {
+	const digits = "0123456789"
 	// Optional leading sign.
 	l.accept("+-")
-	// Is it hex?
-	digits := "0123456789"
-	if l.accept("0") && l.accept("xX") {
-		digits = "0123456789abcdefABCDEF"
-	}
-	l.acceptRun(digits)
+	mantissa := l.acceptCount(digits)
 	if l.accept(".") {
-		l.acceptRun(digits)
+		mantissa += l.acceptCount(digits)
 	}
-	if l.accept("e") {
+	if mantissa == 0 {
+		return l.errorf("malformed number %q", l.input[l.start:l.pos])
+	}
+	// An exponent is only present if digits follow the 'e'.
+	if mark := l.pos; l.accept("eE") {
 		l.accept("+-")
-		l.acceptRun(digits)
+		if l.acceptCount(digits) == 0 {
+			l.pos = mark
+		}
 	}
 	l.emit(ItemNumber)
 	return lexD
 }
 
+// lexFlag scans a single character arc flag.
+func lexFlag(l *Lexer) stateFn {
+	l.accept("01")
+	l.emit(ItemFlag)
+	return lexD
+}
+
+// acceptCount consumes a run of runes from the valid set and returns
+// how many were consumed.
+func (l *Lexer) acceptCount(valid string) int {
+	n := 0
+	for l.accept(valid) {
+		n++
+	}
+	return n
+}
+
+// errorf emits an ItemError describing the problem and terminates
+// the scan.
+func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
+	l.Items <- Item{ItemError, fmt.Sprintf(format, args...), l.start, &l.name}
+	return nil
+}
+
 func (l *Lexer) ignore() {
 	l.start = l.pos
 }
@@ -170,16 +228,34 @@ func (l *Lexer) emit(t ItemType) {
 	i := Item{t, l.input[l.start:l.pos], l.start, &l.name}
 	l.Items <- i
 	l.start = l.pos
+
+	if !l.arcs {
+		return
+	}
+	// Track the argument position within elliptical arc
+	// commands, so their flags can be recognized.
+	switch t {
+	case ItemLetter, ItemWord:
+		if strings.HasSuffix(i.Value, "a") || strings.HasSuffix(i.Value, "A") {
+			l.arcarg = 0
+		} else {
+			l.arcarg = -1
+		}
+	case ItemNumber, ItemFlag:
+		if l.arcarg >= 0 {
+			l.arcarg = (l.arcarg + 1) % 7
+		}
+	}
 }
 
 func lexWord(l *Lexer) stateFn {
-	l.acceptRun("abcdefghijklmnopqrstuwxyzABCDEFGHIJKLMNOPQRSTUWXYZ")
+	l.acceptRun("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	l.emit(ItemWord)
 	return lexD
 }
 
 func lexLetter(l *Lexer) stateFn {
-	l.accept("abcdefghijklmnopqrstuwxyzABCDEFGHIJKLMNOPQRSTUWXYZ")
+	l.accept("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	if unicode.IsLetter(l.peek()) {
 		return lexWord
 	}
@@ -188,49 +264,46 @@ func lexLetter(l *Lexer) stateFn {
 }
 
 func lexComma(l *Lexer) stateFn {
-	l.accept(",")
 	l.emit(ItemComma)
 	return lexD
 }
 
 func isWSP(r rune) bool {
-	return r == ' ' || r == '\t' || r == '\n'
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
 }
 
 func lexWSP(l *Lexer) stateFn {
-	l.accept(" \t\r\n\f")
+	l.acceptRun(" \t\r\n\f")
 	l.emit(ItemWSP)
 	return lexD
 }
 
 func lexD(l *Lexer) stateFn {
-	for {
-		r := l.next()
-		switch {
-		case r == eof:
-			l.emit(ItemEOS)
-			return nil
-		case isWSP(r):
-			return lexWSP
-		case unicode.IsLetter(r):
-			return lexLetter
-		case r == '-' || r == '+':
-			return lexNumber
-		case unicode.IsNumber(r):
-			return lexNumber
-		case r == ',':
-			return lexComma
-		case r == '(' || r == ')':
-			return lexParan
-		default:
-			return nil
-		}
+	r := l.next()
+	switch {
+	case r == eof:
+		l.emit(ItemEOS)
+		return nil
+	case isWSP(r):
+		return lexWSP
+	case unicode.IsLetter(r):
+		return lexLetter
+	case (l.arcarg == 3 || l.arcarg == 4) && (r == '0' || r == '1'):
+		l.backup()
+		return lexFlag
+	case r == '-' || r == '+' || r == '.' || ('0' <= r && r <= '9'):
+		l.backup()
+		return lexNumber
+	case r == ',':
+		return lexComma
+	case r == '(' || r == ')':
+		return lexParan
+	default:
+		return l.errorf("unexpected character %q", r)
 	}
-	return nil
 }
 
 func lexParan(l *Lexer) stateFn {
-	l.accept("()")
 	l.emit(ItemParan)
 	return lexD
 }
@@ -0,0 +1,107 @@
+package svger
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Unit is a CSS absolute length unit, expressed as the number of px
+// in one of it. The px is the SVG user unit, and CSS fixes it at 1/96
+// of an inch.
+type Unit float64
+
+// The CSS absolute length units.
+const (
+	Px Unit = 1
+	In Unit = 96
+	Cm Unit = In / 2.54
+	Mm Unit = In / 25.4
+	Q  Unit = In / 101.6
+	Pt Unit = In / 72
+	Pc Unit = In / 6
+)
+
+// units maps the unit suffixes of lengths to their units.
+var units = map[string]Unit{
+	"px": Px,
+	"in": In,
+	"cm": Cm,
+	"mm": Mm,
+	"q":  Q,
+	"pt": Pt,
+	"pc": Pc,
+}
+
+// ParseUnit returns the unit with the given (case insensitive) name,
+// such as "mm".
+func ParseUnit(name string) (Unit, error) {
+	if u, ok := units[strings.ToLower(name)]; ok {
+		return u, nil
+	}
+	return 0, fmt.Errorf("unsupported unit %q", name)
+}
+
+// AtDPI returns the unit for an image authored at dpi px per inch,
+// rather than the CSS standard of 96.
+func (u Unit) AtDPI(dpi float64) Unit {
+	return u * Unit(dpi/96)
+}
+
+// ParseLength parses an SVG length, such as "297.0022mm", and returns
+// its value in px. A number without a unit is in px. A percentage is
+// relative to ref, which is in px.
+func ParseLength(val string, ref float64) (float64, error) {
+	s := strings.TrimSpace(val)
+	u := Px
+	if strings.HasSuffix(s, "%") {
+		s, u = s[:len(s)-1], Unit(ref/100)
+	} else {
+		i := len(s)
+		for i > 0 && ('a' <= s[i-1] && s[i-1] <= 'z' || 'A' <= s[i-1] && s[i-1] <= 'Z') {
+			i--
+		}
+		if i < len(s) {
+			var err error
+			if u, err = ParseUnit(s[i:]); err != nil {
+				return 0, fmt.Errorf("invalid length %q: %v", val, err)
+			}
+			s = s[:i]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid length %q", val)
+	}
+	return f * float64(u), nil
+}
+
+// lengthRef returns the length that a percentage value of the named
+// attribute is relative to, within a viewport of size vp.
+func lengthRef(name string, vp Tuple) float64 {
+	switch name {
+	case "x", "cx", "x1", "x2", "width", "rx":
+		return vp[0]
+	case "y", "cy", "y1", "y2", "height", "ry":
+		return vp[1]
+	}
+	// Other lengths are relative to the normalized diagonal.
+	return math.Hypot(vp[0], vp[1]) / math.Sqrt2
+}
+
+// setLengths resolves the length valued attributes of an element,
+// within a viewport of size vp, into the fields mapped to by their
+// names. Invalid lengths are ignored.
+func setLengths(attrs []xml.Attr, vp Tuple, fields map[string]*float64) {
+	for _, attr := range attrs {
+		f, ok := fields[attr.Name.Local]
+		if !ok || attr.Name.Space != "" {
+			continue
+		}
+		if v, ok := parseLength(attr.Value, lengthRef(attr.Name.Local, vp)); ok {
+			*f = v
+		}
+	}
+}
@@ -1,19 +1,88 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // Line is an SVG XML line element
 type Line struct {
-	ID        string `xml:"id,attr"`
-	Transform string `xml:"transform,attr"`
-	Style     string `xml:"style,attr"`
-	X1        string `xml:"x1,attr"`
-	X2        string `xml:"x2,attr"`
-	Y1        string `xml:"y1,attr"`
-	Y2        string `xml:"y2,attr"`
-
-	transform mtransform.Transform
-	group     *Group
+	ID             string  `xml:"id,attr"`
+	Class          string  `xml:"class,attr"`
+	Transform      string  `xml:"transform,attr"`
+	Style          string  `xml:"style,attr"`
+	X1             float64 `xml:"-"`
+	X2             float64 `xml:"-"`
+	Y1             float64 `xml:"-"`
+	Y2             float64 `xml:"-"`
+	Stroke         string  `xml:"stroke,attr"`
+	StrokeWidth    float64 `xml:"-"`
+	StrokeLineCap  string  `xml:"stroke-linecap,attr"`
+	StrokeLineJoin string  `xml:"stroke-linejoin,attr"`
+
+	group *Group
+	attrs []xml.Attr
+}
+
+// ParseDrawingInstructions implements the DrawingInstructionParser
+// interface
+func (l *Line) ParseDrawingInstructions() chan *DrawingInstruction {
+	if l.group == nil {
+		l.group = new(Group)
+		temp := mt.Identity()
+		l.group.Transform = &temp
+	}
+	cs := l.group.elementStyle("line", l.attrs)
+	pdp := newPathDParse()
+	lineTransform, terr := parseTransform(l.Transform)
+	pdp.transform = mt.MultiplyTransforms(pdp.transform, *l.group.Transform)
+	pdp.transform = mt.MultiplyTransforms(pdp.transform, lineTransform)
+
+	draw := make(chan *DrawingInstruction)
+	go func() {
+		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
+
+		x, y := pdp.transform.Apply(l.X1, l.Y1)
+		draw <- &DrawingInstruction{
+			Kind: MoveInstruction,
+			M:    &Tuple{x, y},
+		}
+		x, y = pdp.transform.Apply(l.X2, l.Y2)
+		draw <- &DrawingInstruction{
+			Kind: LineInstruction,
+			M:    &Tuple{x, y},
+		}
+
+		// A line encloses no area, so it is never filled.
+		cs.Fill = "none"
+		draw <- cs.paintInstruction(l.group.strokeScale(&pdp.transform))
+	}()
+	return draw
+}
+
+// updateStyle sets the exported style fields of the line to their
+// computed values.
+func (l *Line) updateStyle() {
+	cs := l.group.elementStyle("line", l.attrs)
+	l.Stroke, l.StrokeWidth = cs.Stroke, cs.StrokeWidth
+	l.StrokeLineCap, l.StrokeLineJoin = cs.StrokeLineCap, cs.StrokeLineJoin
+}
+
+// setLengths resolves the geometry attributes of the line within a
+// viewport of size vp.
+func (l *Line) setLengths(vp Tuple) {
+	setLengths(l.attrs, vp, map[string]*float64{
+		"x1": &l.X1,
+		"y1": &l.Y1,
+		"x2": &l.X2,
+		"y2": &l.Y2,
+	})
 }
@@ -1,6 +1,10 @@
 package mtransform
 
 import (
+	"errors"
+	"fmt"
+	"math"
+
 	"zappem.net/pub/math/geom"
 )
 
@@ -67,3 +71,124 @@ func (t *Transform) RotatePoint(angle geom.Angle, x float64, y float64) {
 	*t = Transform(geom.Matrix(*t).XM(geom.RZ(angle)))
 	t.multiplyWith(&unshift)
 }
+
+// SkewX extends the transformation to skew along the x axis by an
+// angle, so lines parallel to the y axis are rotated by it.
+func (t *Transform) SkewX(angle geom.Angle) {
+	a := geom.M(1, math.Tan(angle.Rad()), 0,
+		0, 1, 0,
+		0, 0, 1)
+	*t = Transform(geom.Matrix(*t).XM(a))
+}
+
+// SkewY extends the transformation to skew along the y axis by an
+// angle, so lines parallel to the x axis are rotated by it.
+func (t *Transform) SkewY(angle geom.Angle) {
+	a := geom.M(1, 0, 0,
+		math.Tan(angle.Rad()), 1, 0,
+		0, 0, 1)
+	*t = Transform(geom.Matrix(*t).XM(a))
+}
+
+// ErrSingular is returned by Invert and Decompose for transforms that
+// collapse the plane onto a line or point, and so have no inverse.
+var ErrSingular = errors.New("mtransform: singular transform")
+
+// Determinant returns the determinant of the linear part of the
+// transformation. This is the factor by which it scales areas, and it
+// is negative if the transformation mirrors.
+func (t *Transform) Determinant() float64 {
+	m := geom.Matrix(*t)
+	return m[0]*m[4] - m[1]*m[3]
+}
+
+// singular confirms the determinant of t is negligible compared to
+// its elements.
+func (t *Transform) singular() bool {
+	m := geom.Matrix(*t)
+	size := math.Max(math.Max(math.Abs(m[0]), math.Abs(m[1])), math.Max(math.Abs(m[3]), math.Abs(m[4])))
+	det := t.Determinant()
+	return size == 0 || math.Abs(det) <= 1e-12*size*size || math.IsNaN(det) || math.IsInf(det, 0)
+}
+
+// Invert returns the inverse of the transformation, which maps the
+// transformed coordinates back to the original ones.
+func (t *Transform) Invert() (Transform, error) {
+	if t.singular() {
+		return Identity(), ErrSingular
+	}
+	m := geom.Matrix(*t)
+	det := t.Determinant()
+	a, b, c, d := m[4]/det, -m[1]/det, -m[3]/det, m[0]/det
+	return Transform(geom.M(
+		a, b, -(a*m[2] + b*m[5]),
+		c, d, -(c*m[2] + d*m[5]),
+		0, 0, 1)), nil
+}
+
+// ApplyVector transforms a displacement (x,y) into (X,Y) using the
+// transformation, t. Unlike Apply, the translation of t is ignored.
+func (t *Transform) ApplyVector(x float64, y float64) (X float64, Y float64) {
+	m := geom.Matrix(*t)
+	return m[0]*x + m[1]*y, m[3]*x + m[4]*y
+}
+
+// IsIdentity confirms every element of the transformation is within
+// tol of the corresponding element of the identity.
+func (t *Transform) IsIdentity(tol float64) bool {
+	for i, v := range geom.Matrix(*t) {
+		if math.Abs(v-geom.I[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// Decomposition describes an affine transformation as the sequence
+// of simple transformations, translate(TranslateX,TranslateY)
+// rotate(Rotate) skewX(SkewX) scale(ScaleX,ScaleY), applied from right
+// to left. ScaleX is never negative; a mirroring transformation has a
+// negative ScaleY.
+type Decomposition struct {
+	TranslateX, TranslateY float64
+	Rotate                 geom.Angle
+	SkewX                  geom.Angle
+	ScaleX, ScaleY         float64
+}
+
+// Decompose breaks the transformation down into translation,
+// rotation, skew and scaling.
+func (t *Transform) Decompose() (Decomposition, error) {
+	if t.singular() {
+		return Decomposition{}, ErrSingular
+	}
+	m := geom.Matrix(*t)
+	a, b, c, d := m[0], m[3], m[1], m[4]
+	sx := math.Hypot(a, b)
+	det := t.Determinant()
+	sy := det / sx
+	return Decomposition{
+		TranslateX: m[2],
+		TranslateY: m[5],
+		Rotate:     geom.Radians(math.Atan2(b, a)),
+		SkewX:      geom.Radians(math.Atan((a*c + b*d) / det)),
+		ScaleX:     sx,
+		ScaleY:     sy,
+	}, nil
+}
+
+// Transform recomposes the transformation described by d.
+func (d Decomposition) Transform() Transform {
+	t := Translate(d.TranslateX, d.TranslateY)
+	t.RotatePoint(d.Rotate, 0, 0)
+	t.SkewX(d.SkewX)
+	t.Scale(d.ScaleX, d.ScaleY)
+	return t
+}
+
+// String returns the transformation in the SVG syntax,
+// "matrix(a b c d e f)".
+func (t Transform) String() string {
+	m := geom.Matrix(t)
+	return fmt.Sprintf("matrix(%g %g %g %g %g %g)", m[0], m[3], m[1], m[4], m[2], m[5])
+}
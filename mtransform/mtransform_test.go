@@ -0,0 +1,215 @@
+package mtransform
+
+import (
+	"math"
+	"testing"
+
+	"zappem.net/pub/math/geom"
+)
+
+// near confirms two values are equal to within rounding error.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// sameTransform confirms two transforms are equal to within rounding
+// error.
+func sameTransform(a, b Transform) bool {
+	for i, v := range geom.Matrix(a) {
+		if !near(v, geom.Matrix(b)[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matrix returns the transform with SVG matrix(a b c d e f) values.
+func matrix(a, b, c, d, e, f float64) Transform {
+	return Transform(geom.M(
+		a, c, e,
+		b, d, f,
+		0, 0, 1))
+}
+
+func rotated(degrees float64) Transform {
+	t := Identity()
+	t.RotatePoint(geom.Degrees(degrees), 0, 0)
+	return t
+}
+
+func skewedX(degrees float64) Transform {
+	t := Identity()
+	t.SkewX(geom.Degrees(degrees))
+	return t
+}
+
+func TestDeterminant(t *testing.T) {
+	vs := []struct {
+		t   Transform
+		det float64
+	}{
+		{Identity(), 1},
+		{Translate(3, 4), 1},
+		{matrix(2, 0, 0, 3, 5, 6), 6},
+		{matrix(-1, 0, 0, 1, 0, 0), -1},
+		{rotated(30), 1},
+		{skewedX(45), 1},
+		{matrix(1, 2, 2, 4, 0, 0), 0},
+	}
+	for i, v := range vs {
+		if got := v.t.Determinant(); !near(got, v.det) {
+			t.Errorf("test=%d: %v determinant got %g, want %g", i, v.t, got, v.det)
+		}
+	}
+}
+
+func TestInvert(t *testing.T) {
+	vs := []struct {
+		t        Transform
+		singular bool
+	}{
+		{t: Identity()},
+		{t: Translate(3, -4)},
+		{t: matrix(2, 0, 0, 3, 5, 6)},
+		{t: matrix(1, 2, 3, 4, 5, 6)},
+		{t: MultiplyTransforms(rotated(30), skewedX(20))},
+		{t: matrix(1e-5, 0, 0, 1e-5, 1, 1)},
+		{t: matrix(1, 2, 2, 4, 5, 6), singular: true},
+		{t: matrix(0, 0, 0, 0, 5, 6), singular: true},
+	}
+	for i, v := range vs {
+		inv, err := v.t.Invert()
+		if v.singular {
+			if err != ErrSingular {
+				t.Errorf("test=%d: %v inverse got err=%v, want %v", i, v.t, err, ErrSingular)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test=%d: %v inverse failed: %v", i, v.t, err)
+			continue
+		}
+		if got := MultiplyTransforms(v.t, inv); !sameTransform(got, Identity()) {
+			t.Errorf("test=%d: %v x %v = %v, want identity", i, v.t, inv, got)
+		}
+		if got := MultiplyTransforms(inv, v.t); !sameTransform(got, Identity()) {
+			t.Errorf("test=%d: %v x %v = %v, want identity", i, inv, v.t, got)
+		}
+	}
+}
+
+func TestApplyVector(t *testing.T) {
+	vs := []struct {
+		t          Transform
+		x, y, X, Y float64
+	}{
+		{Identity(), 1, 2, 1, 2},
+		{Translate(3, 4), 1, 2, 1, 2},
+		{matrix(2, 0, 0, 3, 5, 6), 1, 1, 2, 3},
+		{matrix(1, 2, 3, 4, 5, 6), 1, 1, 4, 6},
+		{rotated(90), 1, 0, 0, 1},
+		{skewedX(45), 0, 1, 1, 1},
+	}
+	for i, v := range vs {
+		if X, Y := v.t.ApplyVector(v.x, v.y); !near(X, v.X) || !near(Y, v.Y) {
+			t.Errorf("test=%d: %v maps vector (%g,%g) to (%g,%g), want (%g,%g)", i, v.t, v.x, v.y, X, Y, v.X, v.Y)
+		}
+	}
+}
+
+func TestIsIdentity(t *testing.T) {
+	vs := []struct {
+		t    Transform
+		tol  float64
+		want bool
+	}{
+		{Identity(), 0, true},
+		{*NewTransform(), 0, true},
+		{Translate(1e-9, 0), 0, false},
+		{Translate(1e-9, 0), 1e-6, true},
+		{MultiplyTransforms(rotated(30), rotated(-30)), 1e-12, true},
+		{matrix(1.001, 0, 0, 1, 0, 0), 1e-6, false},
+		{matrix(1.001, 0, 0, 1, 0, 0), 1e-2, true},
+		{rotated(180), 1e-6, false},
+	}
+	for i, v := range vs {
+		if got := v.t.IsIdentity(v.tol); got != v.want {
+			t.Errorf("test=%d: %v IsIdentity(%g) got %v, want %v", i, v.t, v.tol, got, v.want)
+		}
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	vs := []struct {
+		t        Transform
+		want     Decomposition
+		singular bool
+	}{
+		{t: Identity(), want: Decomposition{ScaleX: 1, ScaleY: 1}},
+		{t: Translate(3, 4), want: Decomposition{TranslateX: 3, TranslateY: 4, ScaleX: 1, ScaleY: 1}},
+		{t: matrix(2, 0, 0, 3, 0, 0), want: Decomposition{ScaleX: 2, ScaleY: 3}},
+		{t: matrix(1, 0, 0, -1, 0, 0), want: Decomposition{ScaleX: 1, ScaleY: -1}},
+		{t: rotated(30), want: Decomposition{Rotate: geom.Degrees(30), ScaleX: 1, ScaleY: 1}},
+		{t: skewedX(20), want: Decomposition{SkewX: geom.Degrees(20), ScaleX: 1, ScaleY: 1}},
+		{
+			t: Decomposition{
+				TranslateX: -5, TranslateY: 7,
+				Rotate: geom.Degrees(-120),
+				SkewX:  geom.Degrees(15),
+				ScaleX: 0.5, ScaleY: 4,
+			}.Transform(),
+			want: Decomposition{
+				TranslateX: -5, TranslateY: 7,
+				Rotate: geom.Degrees(-120),
+				SkewX:  geom.Degrees(15),
+				ScaleX: 0.5, ScaleY: 4,
+			},
+		},
+		{t: matrix(1, 2, 3, 4, 5, 6)},
+		{t: matrix(1, 2, 2, 4, 0, 0), singular: true},
+	}
+	for i, v := range vs {
+		d, err := v.t.Decompose()
+		if v.singular {
+			if err != ErrSingular {
+				t.Errorf("test=%d: %v decompose got err=%v, want %v", i, v.t, err, ErrSingular)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test=%d: %v decompose failed: %v", i, v.t, err)
+			continue
+		}
+		if got := d.Transform(); !sameTransform(got, v.t) {
+			t.Errorf("test=%d: %v recomposed as %v from %+v", i, v.t, got, d)
+		}
+		if v.want == (Decomposition{}) {
+			continue
+		}
+		if !near(d.TranslateX, v.want.TranslateX) || !near(d.TranslateY, v.want.TranslateY) ||
+			!near(d.Rotate.Rad(), v.want.Rotate.Rad()) || !near(d.SkewX.Rad(), v.want.SkewX.Rad()) ||
+			!near(d.ScaleX, v.want.ScaleX) || !near(d.ScaleY, v.want.ScaleY) {
+			t.Errorf("test=%d: %v decomposed as %+v, want %+v", i, v.t, d, v.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	vs := []struct {
+		t    Transform
+		want string
+	}{
+		{Identity(), "matrix(1 0 0 1 0 0)"},
+		{Translate(3, -4.5), "matrix(1 0 0 1 3 -4.5)"},
+		{matrix(1, 2, 3, 4, 5, 6), "matrix(1 2 3 4 5 6)"},
+		{matrix(0.25, 0, 0, 1e-7, 0, 0), "matrix(0.25 0 0 1e-07 0 0)"},
+	}
+	for i, v := range vs {
+		if got := v.t.String(); got != v.want {
+			t.Errorf("test=%d: got %q, want %q", i, got, v.want)
+		}
+		if got := (&v.t).String(); got != v.want {
+			t.Errorf("test=%d: pointer got %q, want %q", i, got, v.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package svger
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	gl "zappem.net/pub/graphics/svger/genericlexer"
 	"zappem.net/pub/graphics/svger/mtransform"
@@ -12,7 +13,7 @@ import (
 func parseNumber(i gl.Item) (float64, error) {
 	var n float64
 	var ok error
-	if i.Type == gl.ItemNumber {
+	if i.Type == gl.ItemNumber || i.Type == gl.ItemFlag {
 		n, ok = strconv.ParseFloat(i.Value, 64)
 		if ok != nil {
 			return n, fmt.Errorf("Error passing number %s", ok)
@@ -37,9 +38,13 @@ func parseTuple(l *gl.Lexer) (Tuple, error) {
 		return t, fmt.Errorf("Error parsing Tuple expected Number got: %s", ni.Value)
 	}
 
-	if l.PeekItem().Type == gl.ItemWSP || l.PeekItem().Type == gl.ItemComma {
+	// The coordinates are separated by white space and at most
+	// one comma.
+	l.ConsumeWhiteSpace()
+	if l.PeekItem().Type == gl.ItemComma {
 		l.NextItem()
 	}
+	l.ConsumeWhiteSpace()
 	ni = l.NextItem()
 	if ni.Type == gl.ItemNumber {
 		n, ok := strconv.ParseFloat(ni.Value, 64)
@@ -54,34 +59,91 @@ func parseTuple(l *gl.Lexer) (Tuple, error) {
 	return t, nil
 }
 
+// parsePoints parses the points attribute of a polygon or polyline
+// element: a list of coordinate pairs separated by white space
+// and/or commas.
+func parsePoints(points string) ([]Tuple, error) {
+	l, _ := gl.Lex("points", points)
+	var tuples []Tuple
+	for {
+		l.ConsumeWhiteSpace()
+		l.ConsumeComma()
+		l.ConsumeWhiteSpace()
+		switch i := l.PeekItem(); i.Type {
+		case gl.ItemEOS:
+			return tuples, nil
+		case gl.ItemNumber:
+			t, err := parseTuple(l)
+			if err != nil {
+				return tuples, err
+			}
+			tuples = append(tuples, t)
+		default:
+			return tuples, fmt.Errorf("unexpected %q in points", i.Value)
+		}
+	}
+}
+
+// parseTransform parses an SVG transform list, such as
+// "translate(10,20) rotate(45)". The result applies the listed
+// functions from right to left, that is, it is the product of their
+// transforms in the order listed. An empty list is the identity.
 func parseTransform(tstring string) (mtransform.Transform, error) {
 	lexer, _ := gl.Lex("tlexer", tstring)
+	tm := mtransform.Identity()
+	// separable indicates a function was the last thing parsed,
+	// so it may be followed by a comma.
+	separable := false
 	for {
 		i := lexer.NextItem()
 		switch i.Type {
 		case gl.ItemEOS:
-			return mtransform.Identity(),
-				fmt.Errorf("transform parse failed")
-		case gl.ItemWord:
+			if !separable && strings.TrimSpace(tstring) != "" {
+				return mtransform.Identity(), fmt.Errorf("transform %q: expected function after comma", tstring)
+			}
+			return tm, nil
+		case gl.ItemWSP:
+		case gl.ItemComma:
+			if !separable {
+				return mtransform.Identity(), fmt.Errorf("transform %q: unexpected comma", tstring)
+			}
+			separable = false
+		case gl.ItemWord, gl.ItemLetter:
+			var t mtransform.Transform
+			var err error
 			switch i.Value {
 			case "matrix":
-				return parseMatrix(lexer)
+				t, err = parseMatrix(lexer)
 			case "translate":
-				return parseTranslate(lexer)
+				t, err = parseTranslate(lexer)
 			case "rotate":
-				return parseRotate(lexer)
+				t, err = parseRotate(lexer)
 			case "scale":
-				return parseScale(lexer)
+				t, err = parseScale(lexer)
+			case "skewX":
+				t, err = parseSkew(lexer, (*mtransform.Transform).SkewX)
+			case "skewY":
+				t, err = parseSkew(lexer, (*mtransform.Transform).SkewY)
+			default:
+				err = fmt.Errorf("unknown function %q", i.Value)
+			}
+			if err != nil {
+				return mtransform.Identity(), fmt.Errorf("transform %q: %v", tstring, err)
 			}
+			tm = mtransform.MultiplyTransforms(tm, t)
+			separable = true
+		case gl.ItemError:
+			return mtransform.Identity(), fmt.Errorf("transform %q: %s", tstring, i.Value)
+		default:
+			return mtransform.Identity(), fmt.Errorf("transform %q: unexpected %q", tstring, i.Value)
 		}
 	}
 }
 
 func parseMatrix(l *gl.Lexer) (mtransform.Transform, error) {
-	nums, err := parseParenNumList(l)
-	if err != nil || len(nums) != 6 {
-		return mtransform.Identity(),
-			fmt.Errorf("Error Parsing Transform Matrix: %v", err)
+	nums, err := parseParenNumList(l, "matrix", 6)
+	if err != nil {
+		return mtransform.Identity(), err
 	}
 	tm := mtransform.Transform(geom.M(
 		nums[0], nums[2], nums[4],
@@ -91,18 +153,23 @@ func parseMatrix(l *gl.Lexer) (mtransform.Transform, error) {
 }
 
 func parseTranslate(l *gl.Lexer) (mtransform.Transform, error) {
-	nums, err := parseParenNumList(l)
-	if err != nil || len(nums) != 2 {
-		return mtransform.Identity(), fmt.Errorf("Error Parsing Translate: %v", err)
+	nums, err := parseParenNumList(l, "translate", 1, 2)
+	if err != nil {
+		return mtransform.Identity(), err
 	}
-	tm := mtransform.Translate(nums[0], nums[1])
-	return tm, nil
+	x, y := nums[0], 0.0
+	if len(nums) == 2 {
+		y = nums[1]
+	}
+	return mtransform.Translate(x, y), nil
 }
 
+// parseRotate parses the arguments of a rotate function. As for all
+// SVG transform functions, the angle is in degrees.
 func parseRotate(l *gl.Lexer) (mtransform.Transform, error) {
-	nums, err := parseParenNumList(l)
-	if err != nil || (len(nums) != 1 && len(nums) != 3) {
-		return mtransform.Identity(), fmt.Errorf("Error Parsing Rotate: %v", err)
+	nums, err := parseParenNumList(l, "rotate", 1, 3)
+	if err != nil {
+		return mtransform.Identity(), err
 	}
 	a, px, py := nums[0], 0.0, 0.0
 	if len(nums) == 3 {
@@ -110,14 +177,14 @@ func parseRotate(l *gl.Lexer) (mtransform.Transform, error) {
 	}
 
 	tm := mtransform.Identity()
-	(&tm).RotatePoint(geom.Radians(a), px, py)
+	(&tm).RotatePoint(geom.Degrees(a), px, py)
 	return tm, nil
 }
 
 func parseScale(l *gl.Lexer) (mtransform.Transform, error) {
-	nums, err := parseParenNumList(l)
-	if err != nil || (len(nums) != 1 && len(nums) != 2) {
-		return mtransform.Identity(), fmt.Errorf("Error Parsing Scale: %v", err)
+	nums, err := parseParenNumList(l, "scale", 1, 2)
+	if err != nil {
+		return mtransform.Identity(), err
 	}
 	x, y := nums[0], nums[0]
 	if len(nums) == 2 {
@@ -128,32 +195,51 @@ func parseScale(l *gl.Lexer) (mtransform.Transform, error) {
 	return tm, nil
 }
 
-// Parse a parenthesized list of ncount numbers.
-func parseParenNumList(l *gl.Lexer) ([]float64, error) {
-	i := l.NextItem()
-	if i.Type != gl.ItemParan {
-		return nil, fmt.Errorf("Expected Opening Parantheses")
+// parseSkew parses the angle, in degrees, of a skewX or skewY
+// function, which skew applies.
+func parseSkew(l *gl.Lexer, skew func(*mtransform.Transform, geom.Angle)) (mtransform.Transform, error) {
+	nums, err := parseParenNumList(l, "skew", 1)
+	if err != nil {
+		return mtransform.Identity(), err
+	}
+	tm := mtransform.Identity()
+	skew(&tm, geom.Degrees(nums[0]))
+	return tm, nil
+}
+
+// parseParenNumList parses the parenthesized argument list of the
+// transform function fn, which must hold one of counts numbers.
+func parseParenNumList(l *gl.Lexer, fn string, counts ...int) ([]float64, error) {
+	l.ConsumeWhiteSpace()
+	if i := l.NextItem(); i.Type != gl.ItemParan || i.Value != "(" {
+		return nil, fmt.Errorf("%s: expected opening parenthesis, got %v", fn, i)
 	}
 	var nums []float64
+	comma := false
 	for {
-		if len(nums) > 0 {
-			for l.PeekItem().Type == gl.ItemComma || l.PeekItem().Type == gl.ItemWSP {
-				l.NextItem()
+		l.ConsumeWhiteSpace()
+		i := l.NextItem()
+		switch {
+		case i.Type == gl.ItemParan && i.Value == ")" && !comma:
+			for _, n := range counts {
+				if len(nums) == n {
+					return nums, nil
+				}
 			}
+			return nil, fmt.Errorf("%s: unexpected number of arguments %d", fn, len(nums))
+		case i.Type == gl.ItemComma && len(nums) > 0 && !comma:
+			comma = true
+		case i.Type == gl.ItemNumber:
+			n, err := parseNumber(i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fn, err)
+			}
+			nums = append(nums, n)
+			comma = false
+		case i.Type == gl.ItemEOS:
+			return nil, fmt.Errorf("%s: missing closing parenthesis", fn)
+		default:
+			return nil, fmt.Errorf("%s: unexpected %q", fn, i.Value)
 		}
-		if l.PeekItem().Type == gl.ItemParan {
-			l.NextItem() // consume Parantheses
-			break
-		}
-		if l.PeekItem().Type != gl.ItemNumber {
-			return nil, fmt.Errorf("Expected Number got %v", l.PeekItem().String())
-		}
-		n, err := parseNumber(l.NextItem())
-		if err != nil {
-			return nil, err
-		}
-		nums = append(nums, n)
-
 	}
-	return nums, nil
 }
@@ -1,6 +1,10 @@
 package svger
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -75,3 +79,906 @@ L108.8143 67.9765
 		}
 	}
 }
+
+type ShapeTest struct {
+	Description string
+	Svg         string
+	// Kinds lists the expected instruction kinds, and Points the
+	// expected end points of each Move, Line and Curve
+	// instruction.
+	Kinds  []InstructionType
+	Points []Tuple
+}
+
+var shapeTests = []ShapeTest{
+	{
+		"ellipse",
+		`<svg><ellipse cx="10" cy="20" rx="5" ry="3"/></svg>`,
+		[]InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{15, 20}, {10, 23}, {5, 20}, {10, 17}, {15, 20}},
+	},
+	{
+		"circle distorted by its transform",
+		`<svg><circle r="1" transform="scale(2,1)"/></svg>`,
+		[]InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{2, 0}, {0, 1}, {-2, 0}, {0, -1}, {2, 0}},
+	},
+	{
+		"rotated and scaled ellipse",
+		`<svg><g transform="scale(2,1)"><ellipse cx="10" cy="0" rx="5" ry="3" transform="matrix(0 1 -1 0 0 0)"/></g></svg>`,
+		[]InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{0, 15}, {-6, 10}, {0, 5}, {6, 10}, {0, 15}},
+	},
+	{
+		"degenerate ellipse",
+		`<svg><ellipse cx="10" cy="20" rx="0" ry="3"/></svg>`,
+		nil,
+		nil,
+	},
+	{
+		"line",
+		`<svg><line x1="1" y1="2" x2="3" y2="4"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{1, 2}, {3, 4}},
+	},
+	{
+		"translated line",
+		`<svg><g transform="translate(10,20)"><line x1="1" y1="2" x2="3" y2="4" transform="scale(2)"/></g></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{12, 24}, {16, 28}},
+	},
+	{
+		"polygon",
+		`<svg><polygon points="0,0 10,0, 10 10 -5-5"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, LineInstruction, LineInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{0, 0}, {10, 0}, {10, 10}, {-5, -5}},
+	},
+	{
+		"scaled polyline",
+		`<svg><g transform="scale(2,3)"><polyline points="0,0 10,0 10,10"/></g></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{0, 0}, {20, 0}, {20, 30}},
+	},
+	{
+		"odd polyline",
+		`<svg><polyline points="0,0 10,0 10"/></svg>`,
+		[]InstructionType{ErrorInstruction},
+		nil,
+	},
+	{
+		"rect",
+		`<svg><rect x="1" y="2" width="10" height="20"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, LineInstruction, LineInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{1, 2}, {11, 2}, {11, 22}, {1, 22}},
+	},
+	{
+		"rounded rect",
+		`<svg><rect x="0" y="0" width="10" height="20" rx="2" ry="3"/></svg>`,
+		[]InstructionType{MoveInstruction,
+			LineInstruction, CurveInstruction, LineInstruction, CurveInstruction,
+			LineInstruction, CurveInstruction, LineInstruction, CurveInstruction,
+			CloseInstruction, PaintInstruction},
+		[]Tuple{{2, 0}, {8, 0}, {10, 3}, {10, 17}, {8, 20}, {2, 20}, {0, 17}, {0, 3}, {2, 0}},
+	},
+	{
+		"rounded rect with implied and clamped ry",
+		`<svg><rect x="0" y="0" width="20" height="10" rx="6"/></svg>`,
+		[]InstructionType{MoveInstruction,
+			LineInstruction, CurveInstruction, CurveInstruction,
+			LineInstruction, CurveInstruction, CurveInstruction,
+			CloseInstruction, PaintInstruction},
+		[]Tuple{{6, 0}, {14, 0}, {20, 5}, {14, 10}, {6, 10}, {0, 5}, {6, 0}},
+	},
+	{
+		"rounded rect with clamped rx",
+		`<svg><rect x="0" y="0" width="10" height="10" ry="20"/></svg>`,
+		[]InstructionType{MoveInstruction,
+			CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction,
+			CloseInstruction, PaintInstruction},
+		[]Tuple{{5, 0}, {10, 5}, {5, 10}, {0, 5}, {5, 0}},
+	},
+	{
+		"use of defs",
+		`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><defs><line id="l" x1="1" y1="2" x2="3" y2="4"/></defs>
+<g transform="translate(10,20)"><use xlink:href="#l" x="100" y="200" transform="scale(2)"/></g></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{212, 424}, {216, 428}},
+	},
+	{
+		"use of symbol and group",
+		`<svg><symbol id="s"><g id="pair" transform="translate(1,0)"><line x2="1"/><use href="#dot"/></g></symbol>
+<polyline id="dot" points="0,0 0,1"/>
+<use href="#s" y="5"/><use href="#pair" x="10"/></svg>`,
+		[]InstructionType{
+			MoveInstruction, LineInstruction, PaintInstruction,
+			MoveInstruction, LineInstruction, PaintInstruction,
+			MoveInstruction, LineInstruction, PaintInstruction,
+			MoveInstruction, LineInstruction, PaintInstruction,
+			MoveInstruction, LineInstruction, PaintInstruction,
+		},
+		[]Tuple{
+			{0, 0}, {0, 1},
+			{1, 5}, {2, 5}, {1, 5}, {1, 6},
+			{11, 0}, {12, 0}, {11, 0}, {11, 1},
+		},
+	},
+	{
+		"missing use reference",
+		`<svg><use href="#nothing"/></svg>`,
+		nil,
+		nil,
+	},
+	{
+		"use reference cycle",
+		`<svg><g id="a"><line x2="1"/><use href="#b"/></g><use id="b" href="#a"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction, MoveInstruction, LineInstruction, PaintInstruction, ErrorInstruction},
+		[]Tuple{{0, 0}, {1, 0}, {0, 0}, {1, 0}},
+	},
+	{
+		"self referencing use",
+		`<svg><use id="u" href="#u"/></svg>`,
+		[]InstructionType{ErrorInstruction},
+		nil,
+	},
+	{
+		"chained element transform",
+		`<svg><line x2="1" transform="translate(10,20) rotate(90)"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{10, 20}, {10, 21}},
+	},
+	{
+		"malformed element transform",
+		`<svg><line x2="1" transform="translate(10,20) spin(90)"/></svg>`,
+		[]InstructionType{ErrorInstruction},
+		nil,
+	},
+	{
+		"viewBox default alignment",
+		`<svg width="200" height="100" viewBox="0 0 10 10"><line x2="10" y2="10"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{50, 0}, {150, 100}},
+	},
+	{
+		"viewBox xMinYMax slice",
+		`<svg width="200" height="100" viewBox="0 0 10 10" preserveAspectRatio="xMinYMax slice"><line x2="10" y2="10"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{0, -100}, {200, 100}},
+	},
+	{
+		"viewBox without aspect ratio",
+		`<svg width="200" height="100" viewBox="0 0 10 10" preserveAspectRatio="none"><line x2="10" y2="10"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{0, 0}, {200, 100}},
+	},
+	{
+		"viewBox with commas and no size",
+		`<svg viewBox=" 10,20  10,10 "><line x1="10" y1="20" x2="20" y2="30"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{0, 0}, {10, 10}},
+	},
+	{
+		"viewBox with width only",
+		`<svg width="20px" viewBox="0 0 10 5"><line x2="10" y2="5"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{0, 0}, {20, 10}},
+	},
+	{
+		"group transforms compose with the viewport",
+		`<svg width="20" height="20" viewBox="0 0 10 10"><g transform="translate(1,1)"><g transform="translate(1,0)"><line x2="1" y2="1"/></g></g></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{4, 2}, {6, 4}},
+	},
+	{
+		"nested svg viewport",
+		`<svg width="100" height="100" viewBox="0 0 100 100"><svg x="10" y="10" width="50%" height="20" viewBox="0 0 5 5" preserveAspectRatio="xMaxYMid"><line x2="5" y2="5"/></svg></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{40, 10}, {60, 30}},
+	},
+	{
+		"use of a symbol with a viewBox",
+		`<svg width="100" height="100" viewBox="0 0 100 100"><symbol id="s" viewBox="0 0 10 10"><line x2="10" y2="10"/></symbol><use href="#s" x="5" y="5"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{5, 5}, {105, 105}},
+	},
+	{
+		"use of a nested svg",
+		`<svg width="100" height="100"><defs><svg id="n" x="1" width="20" height="20" viewBox="0 0 2 2"><line x2="2" y2="2"/></svg></defs><use href="#n" y="3"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{1, 3}, {21, 23}},
+	},
+	{
+		"rect with percentage lengths",
+		`<svg viewBox="0 0 200 100"><rect x="10%" y="10%" width="50%" height="50%"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, LineInstruction, LineInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{20, 10}, {120, 10}, {120, 60}, {20, 60}},
+	},
+	{
+		"circle with unit and percentage lengths",
+		`<svg viewBox="0 0 100 100"><circle cx="1in" r="10%" transform="scale(2,1)"/></svg>`,
+		[]InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{212, 0}, {192, 10}, {172, 0}, {192, -10}, {212, 0}},
+	},
+	{
+		"ellipse with percentage lengths",
+		`<svg viewBox="0 0 200 100"><ellipse cx="50%" cy="50%" rx="10%" ry="10%"/></svg>`,
+		[]InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CloseInstruction, PaintInstruction},
+		[]Tuple{{120, 50}, {100, 60}, {80, 50}, {100, 40}, {120, 50}},
+	},
+	{
+		"line and use with unit lengths",
+		`<svg viewBox="0 0 200 100"><defs><line id="l" x2="1in" y2="2.54cm"/></defs><use href="#l" x="10%" y="1pc"/></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{20, 16}, {116, 112}},
+	},
+	{
+		"nested svg in a group without viewBox",
+		`<svg width="100" height="100"><g transform="translate(5,5)"><svg x="1" y="2"><line x2="1"/></svg></g></svg>`,
+		[]InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		[]Tuple{{6, 7}, {7, 7}},
+	},
+}
+
+func TestShapes(t *testing.T) {
+	for _, test := range shapeTests {
+		svg, err := parseSvg(strings.NewReader(test.Svg), "test", 1, true)
+		if err != nil {
+			t.Fatalf("ParseSvg failed for test %s: %v", test.Description, err)
+		}
+		var dis []*DrawingInstruction
+		for di := range svg.ParseDrawingInstructions() {
+			dis = append(dis, di)
+		}
+		if len(dis) != len(test.Kinds) {
+			t.Errorf("test %s: expected %d instructions, got %d", test.Description, len(test.Kinds), len(dis))
+			continue
+		}
+		var points []*Tuple
+		for i, di := range dis {
+			if di.Kind != test.Kinds[i] {
+				t.Errorf("test %s: instruction %d is %v, expected %v", test.Description, i, di.Kind, test.Kinds[i])
+			}
+			if pt := endPoint(di); pt != nil {
+				points = append(points, pt)
+			}
+		}
+		if len(points) != len(test.Points) {
+			t.Errorf("test %s: expected %d points, got %d", test.Description, len(test.Points), len(points))
+			continue
+		}
+		for i, pt := range points {
+			if !closeTuple(pt, &test.Points[i]) {
+				t.Errorf("test %s: point %d is %v, expected %v", test.Description, i, *pt, test.Points[i])
+			}
+		}
+	}
+}
+
+type StyleTest struct {
+	Description string
+	Svg         string
+	// Paints lists the expected fill, stroke, stroke-width,
+	// stroke-linecap and stroke-linejoin of each PaintInstruction.
+	Paints []PaintStyle
+}
+
+type PaintStyle struct {
+	Fill, Stroke string
+	Width        float64
+	Cap, Join    string
+}
+
+var styleTests = []StyleTest{
+	{
+		"defaults",
+		`<svg><path d="M0 0 L1 1"/><circle r="1"/></svg>`,
+		[]PaintStyle{
+			{"black", "none", 1, "butt", "miter"},
+			{"black", "none", 1, "butt", "miter"},
+		},
+	},
+	{
+		"root svg presentation attributes",
+		`<svg fill="none" stroke="currentColor" color="red" stroke-width="2"><line x2="1"/><g><rect width="1" height="1"/></g></svg>`,
+		[]PaintStyle{
+			{"none", "red", 2, "butt", "miter"},
+			{"none", "red", 2, "butt", "miter"},
+		},
+	},
+	{
+		"style attribute beats presentation attribute",
+		`<svg><rect width="1" height="1" fill="red" style="fill:#009FE3"/><ellipse rx="1" ry="1" style="stroke: blue ; stroke-width : 3" stroke="green"/></svg>`,
+		[]PaintStyle{
+			{"#009FE3", "none", 1, "butt", "miter"},
+			{"black", "blue", 3, "butt", "miter"},
+		},
+	},
+	{
+		"group inheritance",
+		`<svg><g style="fill:none; stroke:#000000; stroke-width:0.15; stroke-linecap:round; stroke-linejoin:round;" stroke="red">
+<g stroke-linecap="square"><path d="M0 0 L1 1" stroke="green"/><polygon points="0,0 1,1" stroke="inherit"/></g>
+<polyline points="0,0 1,1" style="stroke-linejoin:bevel"/></g></svg>`,
+		[]PaintStyle{
+			{"none", "green", 0.15, "square", "round"},
+			{"none", "#000000", 0.15, "square", "round"},
+			{"none", "#000000", 0.15, "round", "bevel"},
+		},
+	},
+	{
+		"opacity and invalid values",
+		`<svg><g stroke="red" stroke-width="2"><path d="M0 0 L1 1" style="fill-opacity:0;stroke-width:bogus;stroke-linecap:sideways"/><path d="M0 0 L1 1" stroke-opacity="0"/></g></svg>`,
+		[]PaintStyle{
+			{"none", "red", 2, "butt", "miter"},
+			{"black", "none", 2, "butt", "miter"},
+		},
+	},
+	{
+		"illustrator stylesheet",
+		`<svg><style type="text/css">
+	.st0{fill:#009FE3;}
+	.st1{fill:none;stroke:#000000;stroke-miterlimit:10;}
+</style>
+<rect class="st0" width="1" height="1"/><g><path class="st1 other" d="M0 0 L1 1"/></g></svg>`,
+		[]PaintStyle{
+			{"#009FE3", "none", 1, "butt", "miter"},
+			{"none", "#000000", 1, "butt", "miter"},
+		},
+	},
+	{
+		"selector specificity",
+		`<svg><defs><style><![CDATA[
+/* later rules of equal specificity win */
+path { fill: red; stroke: blue }
+path { fill: green }
+#p1 { fill: yellow }
+g.layer path, circle { stroke: orange; stroke-width: 2 }
+g > path { stroke-linecap: round }
+svg path { stroke-linejoin: bevel }
+.important { stroke: purple !important }
+@media print { path { fill: black } }
+path:hover, [fill] { fill: pink }
+]]></style></defs>
+<path d="M0 0 L1 1"/>
+<path id="p1" d="M0 0 L1 1" fill="white"/>
+<g class="layer"><path d="M0 0 L1 1" style="fill:gray"/><g><path class="important" d="M0 0 L1 1" style="stroke:cyan"/></g></g>
+<circle r="1"/>
+</svg>`,
+		[]PaintStyle{
+			{"green", "blue", 1, "butt", "bevel"},
+			{"yellow", "blue", 1, "butt", "bevel"},
+			{"gray", "orange", 2, "round", "bevel"},
+			{"green", "purple", 2, "round", "bevel"},
+			{"black", "orange", 2, "butt", "miter"},
+		},
+	},
+	{
+		"use inheritance",
+		`<svg><defs><path id="p" d="M0 0 L1 1" stroke-width="3"/></defs><g stroke="red"><use href="#p" fill="none"/></g></svg>`,
+		[]PaintStyle{
+			{"none", "red", 3, "butt", "miter"},
+		},
+	},
+}
+
+func TestStyleCascade(t *testing.T) {
+	for _, test := range styleTests {
+		svg, err := ParseSvg(test.Svg, "test", 0)
+		if err != nil {
+			t.Fatalf("ParseSvg failed for test %s: %v", test.Description, err)
+		}
+		var paints []PaintStyle
+		for di := range svg.ParseDrawingInstructions() {
+			if di.Error != nil {
+				t.Fatalf("test %s failed: %v", test.Description, di.Error)
+			}
+			if di.Kind == PaintInstruction {
+				paints = append(paints, PaintStyle{*di.Fill, *di.Stroke, *di.StrokeWidth, *di.StrokeLineCap, *di.StrokeLineJoin})
+			}
+		}
+		if len(paints) != len(test.Paints) {
+			t.Errorf("test %s: expected %d paints, got %d", test.Description, len(test.Paints), len(paints))
+			continue
+		}
+		for i, want := range test.Paints {
+			if paints[i] != want {
+				t.Errorf("test %s: paint %d is %+v, expected %+v", test.Description, i, paints[i], want)
+			}
+		}
+	}
+}
+
+func TestElementByID(t *testing.T) {
+	svg, err := ParseSvg(`<svg><g id="top" fill="red"><g id="inner"/></g></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	top, ok := svg.ElementByID("top").(*Group)
+	if !ok || top != svg.Groups[0] {
+		t.Errorf("top group is %p, expected %p", top, svg.Groups[0])
+	}
+	inner, ok := svg.ElementByID("inner").(*Group)
+	if !ok || inner.Parent != svg.Groups[0] {
+		t.Fatalf("inner group is not parented by the top group")
+	}
+	if inner.Fill != "red" {
+		t.Errorf("inner group fill is %q, expected red", inner.Fill)
+	}
+}
+
+func TestLateStyle(t *testing.T) {
+	svg, err := ParseSvg(`<svg><g><g id="inner" class="x"/></g><defs><g id="def" class="x"/></defs>
+<style>.x { fill: blue; stroke-width: 2 }</style></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	for _, id := range []string{"inner", "def"} {
+		g, ok := svg.ElementByID(id).(*Group)
+		if !ok {
+			t.Fatalf("no group %q", id)
+		}
+		if g.Fill != "blue" || g.StrokeWidth != 2 {
+			t.Errorf("group %q has fill=%q stroke-width=%v, expected blue and 2", id, g.Fill, g.StrokeWidth)
+		}
+	}
+}
+
+func TestElementStyleFields(t *testing.T) {
+	svg, err := ParseSvg(`<svg><g stroke-width="2"><path id="p" d="M0 0 L1 1" fill="blue"/><rect id="r" width="1" height="1"/></g>
+<style>path, rect { stroke: red }</style></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	p := svg.ElementByID("p").(*Path)
+	if p.Fill == nil || *p.Fill != "blue" || p.Stroke == nil || *p.Stroke != "red" || p.StrokeWidth != 2 {
+		t.Errorf("path style fields are fill=%v stroke=%v stroke-width=%v", p.Fill, p.Stroke, p.StrokeWidth)
+	}
+	r := svg.ElementByID("r").(*Rect)
+	if r.Fill != "black" || r.Stroke != "red" || r.StrokeWidth != 2 {
+		t.Errorf("rect style fields are fill=%q stroke=%q stroke-width=%v", r.Fill, r.Stroke, r.StrokeWidth)
+	}
+}
+
+// TestStrokeWidthAttr confirms that a stroke-width presentation
+// attribute with a unit doesn't prevent an element from parsing.
+func TestStrokeWidthAttr(t *testing.T) {
+	for _, el := range []string{
+		`<ellipse rx="2" ry="1" stroke-width="2px"/>`,
+		`<line x2="1" y2="1" stroke-width="2px"/>`,
+		`<polygon points="0,0 1,0 1,1" stroke-width="2px"/>`,
+		`<polyline points="0,0 1,0 1,1" stroke-width="2px"/>`,
+	} {
+		if _, err := ParseSvg(`<svg>`+el+`</svg>`, "test", 0); err != nil {
+			t.Errorf("ParseSvg failed for %s: %v", el, err)
+		}
+	}
+}
+
+func TestStrokeWidthLengths(t *testing.T) {
+	svg, err := ParseSvg(`<svg viewBox="0 0 30 40"><style>path { stroke-width: 0.25mm } .pc { stroke-width: 10% }</style>
+<path d="M0 0 L1 1"/>
+<path d="M0 0 L1 1" style="stroke-width:0.264583px"/>
+<path class="pc" d="M0 0 L1 1"/>
+<rect width="1" height="1" stroke-width="2px"/>
+<g stroke-width="1in"><circle r="1"/></g>
+</svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	want := []float64{0.25 * 96 / 25.4, 0.264583, 0.1 * 25 * math.Sqrt2, 2, 96}
+	var widths []float64
+	for di := range svg.ParseDrawingInstructions() {
+		if di.Error != nil {
+			t.Fatalf("drawing failed: %v", di.Error)
+		}
+		if di.Kind == PaintInstruction {
+			widths = append(widths, *di.StrokeWidth)
+		}
+	}
+	if len(widths) != len(want) {
+		t.Fatalf("got %d paints, expected %d", len(widths), len(want))
+	}
+	for i, w := range widths {
+		if math.Abs(w-want[i]) > 1e-9 {
+			t.Errorf("paint %d has stroke-width %v, expected %v", i, w, want[i])
+		}
+	}
+}
+
+// TestExampleBoard confirms that ParseSvg still draws the example
+// board where the original version of the package did. The golden
+// file holds its output, rounded to 4 decimal places.
+func TestExampleBoard(t *testing.T) {
+	f, err := os.Open("examples/test-board-F_Cu.svg")
+	if err != nil {
+		t.Fatalf("failed to open example: %v", err)
+	}
+	defer f.Close()
+	svg, err := ParseSvgFromReader(f, "board", 1)
+	if err != nil {
+		t.Fatalf("ParseSvgFromReader failed: %v", err)
+	}
+	var b strings.Builder
+	for di := range svg.ParseDrawingInstructions() {
+		fmt.Fprint(&b, di.Kind)
+		if di.M != nil && di.Kind != CloseInstruction {
+			fmt.Fprintf(&b, " %.4f,%.4f", di.M[0], di.M[1])
+		}
+		if di.CurvePoints != nil {
+			fmt.Fprintf(&b, " %.4f,%.4f", di.CurvePoints.T[0], di.CurvePoints.T[1])
+		}
+		if di.Radius != nil {
+			fmt.Fprintf(&b, " r=%.4f", *di.Radius)
+		}
+		if di.StrokeWidth != nil {
+			fmt.Fprintf(&b, " w=%.4f", *di.StrokeWidth)
+		}
+		if di.Error != nil {
+			fmt.Fprint(&b, " ", di.Error)
+		}
+		fmt.Fprintln(&b)
+	}
+	want, err := os.ReadFile("testdata/test-board-F_Cu.instructions")
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if got := b.String(); got != string(want) {
+		gl, wl := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gl) && i < len(wl); i++ {
+			if gl[i] != wl[i] {
+				t.Fatalf("line %d is %q, want %q", i+1, gl[i], wl[i])
+			}
+		}
+		t.Fatalf("got %d lines, want %d", len(gl), len(wl))
+	}
+}
+
+func TestViewBoxValues(t *testing.T) {
+	vs := []struct {
+		viewBox string
+		want    []float64
+		ok      bool
+	}{
+		{"0 0 10 20", []float64{0, 0, 10, 20}, true},
+		{"-1.5,2  3e1,\t4", []float64{-1.5, 2, 30, 4}, true},
+		{"0 0 10", nil, false},
+		{"0 0 0 10", nil, false},
+		{"0 0 ten 10", nil, false},
+		{"", nil, false},
+	}
+	for i, v := range vs {
+		s := &Svg{ViewBox: v.viewBox}
+		got, err := s.ViewBoxValues()
+		if (err == nil) != v.ok {
+			t.Errorf("test=%d: %q got err=%v, want ok=%v", i, v.viewBox, err, v.ok)
+			continue
+		}
+		if v.ok && !reflect.DeepEqual(got, v.want) {
+			t.Errorf("test=%d: %q got %v, want %v", i, v.viewBox, got, v.want)
+		}
+	}
+}
+
+func TestViewportStrokeWidth(t *testing.T) {
+	svg, err := parseSvg(strings.NewReader(`<svg width="40" height="40" viewBox="0 0 10 10"><line x2="1" stroke-width="0.5"/></svg>`), "test", 1, true)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	for di := range svg.ParseDrawingInstructions() {
+		if di.Kind == PaintInstruction && (di.StrokeWidth == nil || *di.StrokeWidth != 2) {
+			t.Errorf("stroke width got %v, want 2", di.StrokeWidth)
+		}
+	}
+
+	// Without units, the viewBox isn't mapped and only the scale
+	// of ParseSvg applies.
+	svg, err = ParseSvg(`<svg width="40" height="40" viewBox="0 0 10 10"><g transform="scale(3)"><line x2="1" stroke-width="0.5"/></g></svg>`, "test", 2)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	for di := range svg.ParseDrawingInstructions() {
+		switch di.Kind {
+		case LineInstruction:
+			if *di.M != (Tuple{6, 0}) {
+				t.Errorf("line end got %v, want [6 0]", *di.M)
+			}
+		case PaintInstruction:
+			if di.StrokeWidth == nil || *di.StrokeWidth != 1 {
+				t.Errorf("unscaled stroke width got %v, want 1", di.StrokeWidth)
+			}
+		}
+	}
+}
+
+func TestParseLength(t *testing.T) {
+	vs := []struct {
+		val  string
+		ref  float64
+		want float64
+		ok   bool
+	}{
+		{"12", 0, 12, true},
+		{" 12px ", 0, 12, true},
+		{"1in", 0, 96, true},
+		{"2.54cm", 0, 96, true},
+		{"25.4mm", 0, 96, true},
+		{"101.6Q", 0, 96, true},
+		{"72pt", 0, 96, true},
+		{"6pc", 0, 96, true},
+		{"1e1MM", 0, 960 / 25.4, true},
+		{"50%", 300, 150, true},
+		{"2em", 0, 0, false},
+		{"mm", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for i, v := range vs {
+		got, err := ParseLength(v.val, v.ref)
+		if (err == nil) != v.ok {
+			t.Errorf("test=%d: %q got err=%v, want ok=%v", i, v.val, err, v.ok)
+			continue
+		}
+		if v.ok && math.Abs(got-v.want) > 1e-9 {
+			t.Errorf("test=%d: %q got %g, want %g", i, v.val, got, v.want)
+		}
+	}
+}
+
+func TestParseSvgInUnits(t *testing.T) {
+	const src = `<svg width="100mm" height="50mm" viewBox="0 0 200 100"><line x2="200" y2="100" stroke-width="2"/></svg>`
+	vs := []struct {
+		unit   Unit
+		end    Tuple
+		stroke float64
+	}{
+		{Mm, Tuple{100, 50}, 1},
+		{Px, Tuple{100 * float64(Mm), 50 * float64(Mm)}, float64(Mm)},
+		{In, Tuple{100 / 25.4, 50 / 25.4}, 1 / 25.4},
+		{Mm.AtDPI(72), Tuple{100.0 * 96 / 72, 50.0 * 96 / 72}, 96.0 / 72},
+	}
+	for i, v := range vs {
+		svg, err := ParseSvgInUnits(src, "test", v.unit)
+		if err != nil {
+			t.Fatalf("test=%d: ParseSvgInUnits failed: %v", i, err)
+		}
+		for di := range svg.ParseDrawingInstructions() {
+			switch di.Kind {
+			case LineInstruction:
+				if !closeTuple(di.M, &v.end) {
+					t.Errorf("test=%d: line ends at %v, want %v", i, *di.M, v.end)
+				}
+			case PaintInstruction:
+				if math.Abs(*di.StrokeWidth-v.stroke) > 1e-9 {
+					t.Errorf("test=%d: stroke width %g, want %g", i, *di.StrokeWidth, v.stroke)
+				}
+			}
+		}
+	}
+	if _, err := ParseSvgInUnits(src, "test", 0); err == nil {
+		t.Error("ParseSvgInUnits accepted a zero unit")
+	}
+}
+
+// TestTransformAngles confirms that the angles of rotate, skewX and
+// skewY are read as degrees, as the SVG specification requires.
+func TestTransformAngles(t *testing.T) {
+	s, c := math.Sincos(30 * math.Pi / 180)
+	vs := []struct {
+		transform string
+		in, out   Tuple
+	}{
+		{"rotate(30)", Tuple{1, 0}, Tuple{c, s}},
+		{"rotate(-30)", Tuple{1, 0}, Tuple{c, -s}},
+		{"skewX(30)", Tuple{0, 1}, Tuple{s / c, 1}},
+		{"skewY(30)", Tuple{1, 0}, Tuple{1, s / c}},
+	}
+	for i, v := range vs {
+		tm, err := parseTransform(v.transform)
+		if err != nil {
+			t.Errorf("test=%d: %q failed: %v", i, v.transform, err)
+			continue
+		}
+		x, y := tm.Apply(v.in[0], v.in[1])
+		if got := (Tuple{x, y}); !closeTuple(&got, &v.out) {
+			t.Errorf("test=%d: %q maps %v to %v, want %v", i, v.transform, v.in, got, v.out)
+		}
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	vs := []struct {
+		transform string
+		in, out   Tuple
+	}{
+		{"", Tuple{1, 2}, Tuple{1, 2}},
+		{"translate(10)", Tuple{1, 1}, Tuple{11, 1}},
+		{"translate(10,20) rotate(90)", Tuple{1, 0}, Tuple{10, 21}},
+		{"rotate(90) translate(10,20)", Tuple{1, 0}, Tuple{-20, 11}},
+		{"scale(2,3)", Tuple{1, 1}, Tuple{2, 3}},
+		{"scale(2)", Tuple{1, 1}, Tuple{2, 2}},
+		{"rotate(90, 1, 1)", Tuple{2, 1}, Tuple{1, 2}},
+		{"skewX(45)", Tuple{0, 1}, Tuple{1, 1}},
+		{"skewY(45)", Tuple{1, 0}, Tuple{1, 1}},
+		{"matrix(1 2 3 4 5 6)", Tuple{1, 1}, Tuple{9, 12}},
+		{"scale(2),translate(1,0)", Tuple{0, 0}, Tuple{2, 0}},
+		{" translate (1 , 2)scale( 2 ) ", Tuple{1, 1}, Tuple{3, 4}},
+		{"translate(1e1,-.5E1)", Tuple{0, 0}, Tuple{10, -5}},
+	}
+	for i, v := range vs {
+		tm, err := parseTransform(v.transform)
+		if err != nil {
+			t.Errorf("test=%d: %q failed: %v", i, v.transform, err)
+			continue
+		}
+		x, y := tm.Apply(v.in[0], v.in[1])
+		if got := (Tuple{x, y}); !closeTuple(&got, &v.out) {
+			t.Errorf("test=%d: %q maps %v to %v, want %v", i, v.transform, v.in, got, v.out)
+		}
+	}
+	for i, bad := range []string{
+		"rotate(45",
+		"translate(1,,2)",
+		"translate(1,)",
+		"scale()",
+		"rotate(1,2)",
+		"skewX(1 2)",
+		"spin(1)",
+		",scale(2)",
+		"scale(2),",
+		"scale(2),,scale(2)",
+		"scale 2",
+		"translate(1;2)",
+	} {
+		if _, err := parseTransform(bad); err == nil {
+			t.Errorf("test=%d: %q parsed without error", i, bad)
+		}
+	}
+	if _, err := ParseSvg(`<svg><g transform="rotate("><line x2="1"/></g></svg>`, "test", 0); err == nil {
+		t.Error("malformed group transform parsed without error")
+	}
+}
+
+func TestParseSegments(t *testing.T) {
+	svg, err := ParseSvgInUnits(`<svg>
+<path d="M0,0 L10,0 L10,10 z l0,5" stroke="red" stroke-width="2" fill="none"/>
+<polyline points="0,0 1,1 1,1 2,0" fill-rule="evenodd"/>
+<circle cx="0" cy="0" r="1"/>
+<g transform="scale(2)"><rect width="1" height="1"/></g>
+</svg>`, "test", Px)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	const tol = 0.01
+	segs, err := svg.ParseSegments(tol)
+	if err != nil {
+		t.Fatalf("ParseSegments failed: %v", err)
+	}
+	if len(segs) != 5 {
+		t.Fatalf("got %d segments, want 5: %v", len(segs), segs)
+	}
+	want := []Segment{
+		{Width: 2, Closed: true, Points: [][2]float64{{0, 0}, {10, 0}, {10, 10}}, Fill: "none", FillRule: "nonzero", Stroke: "red", LineCap: "butt", LineJoin: "miter", MiterLimit: 4},
+		{Width: 2, Points: [][2]float64{{0, 0}, {0, 5}}, Fill: "none", FillRule: "nonzero", Stroke: "red", LineCap: "butt", LineJoin: "miter", MiterLimit: 4},
+		{Width: 1, Points: [][2]float64{{0, 0}, {1, 1}, {2, 0}}, Fill: "black", FillRule: "evenodd", Stroke: "none", LineCap: "butt", LineJoin: "miter", MiterLimit: 4, Element: 1},
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(segs[i], w) {
+			t.Errorf("segment %d is %+v, want %+v", i, segs[i], w)
+		}
+	}
+	if c := segs[3]; !c.Closed || len(c.Points) < 8 || c.Fill != "black" {
+		t.Errorf("circle segment is %+v", c)
+	} else {
+		for _, pt := range c.Points {
+			if r := math.Hypot(pt[0], pt[1]); math.Abs(r-1) > 1e-9 {
+				t.Errorf("circle vertex %v is not on the circle", pt)
+			}
+		}
+	}
+	if r := segs[4]; !r.Closed || r.Element != 3 || r.Width != 2 || !reflect.DeepEqual(r.Points, [][2]float64{{0, 0}, {2, 0}, {2, 2}, {0, 2}}) {
+		t.Errorf("rect segment is %+v", r)
+	}
+
+	if psegs, err := svg.Elements[0].(*Path).ParseSegments(tol); err != nil || !reflect.DeepEqual(psegs, segs[:2]) {
+		t.Errorf("path segments are %+v (err=%v), want %+v", psegs, err, segs[:2])
+	}
+	// The elements of the group are numbered from zero.
+	wantG := append([]Segment(nil), segs[4:]...)
+	wantG[0].Element = 0
+	if gsegs, err := svg.Groups[0].ParseSegments(tol); err != nil || !reflect.DeepEqual(gsegs, wantG) {
+		t.Errorf("group segments are %+v (err=%v), want %+v", gsegs, err, wantG)
+	}
+
+	bad, err := ParseSvg(`<svg><line x2="1"/><path d="M0,0 L1"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	if segs, err := bad.ParseSegments(tol); err == nil {
+		t.Errorf("bad path data gave segments %v", segs)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	src := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="100" height="50" viewBox="0 0 10 5">
+<style>.a{fill:red}</style>
+<defs><circle id="c" r="1"/></defs>
+<rect id="r" class="a" x="1" y="2" width="3" height="1" transform="translate(1,1)"/>
+<g id="g1" transform="scale(2)" style="stroke:blue;stroke-width:0.1"><path d="M0 0 L1 1"/><use xlink:href="#c" x="2"/></g>
+</svg>`
+	svg, err := ParseSvgInUnits(src, "test", Px)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	svg.Elements[0].(*Rect).Width = 4
+
+	var out strings.Builder
+	if err := svg.Encode(&out, nil); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	for _, want := range []string{
+		`<rect id="r" class="a" x="1" y="2" width="4" height="1" transform="translate(1,1)"/>`,
+		`<g id="g1" transform="scale(2)" style="stroke:blue;stroke-width:0.1">`,
+		`<use xlink:href="#c" x="2"/>`,
+		`<circle id="c" r="1"/>`,
+		`.a{fill:red}`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("encoded svg lacks %q:\n%s", want, out.String())
+		}
+	}
+	again, err := ParseSvgInUnits(out.String(), "again", Px)
+	if err != nil {
+		t.Fatalf("ParseSvg of encoded svg failed: %v", err)
+	}
+	want, err := svg.ParseSegments(0.01)
+	if err != nil {
+		t.Fatalf("ParseSegments failed: %v", err)
+	}
+	if got, err := again.ParseSegments(0.01); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip segments are %+v (err=%v), want %+v", got, err, want)
+	}
+
+	out.Reset()
+	if err := svg.Encode(&out, &EncodeOptions{BakeTransforms: true}); err != nil {
+		t.Fatalf("baked Encode failed: %v", err)
+	}
+	for _, bad := range []string{"transform=", "<use", "<style", "<defs", "class="} {
+		if strings.Contains(out.String(), bad) {
+			t.Errorf("baked svg contains %q:\n%s", bad, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), `<path id="r" d="M2 3 6 3 6 4 2 4Z" fill="red"`) {
+		t.Errorf("baked svg has wrong rect:\n%s", out.String())
+	}
+	baked, err := ParseSvgInUnits(out.String(), "baked", Px)
+	if err != nil {
+		t.Fatalf("ParseSvg of baked svg failed: %v", err)
+	}
+	got, err := baked.ParseSegments(0.01)
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("baked segments are %+v (err=%v), want %+v", got, err, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Closed != w.Closed || g.Fill != w.Fill || g.Stroke != w.Stroke || math.Abs(g.Width-w.Width) > 1e-9 {
+			t.Errorf("baked segment %d is %+v, want %+v", i, g, w)
+			continue
+		}
+		if i == 2 {
+			// The circle is baked as cubic curves, so it
+			// flattens to different vertices.
+			for _, pt := range g.Points {
+				if r := math.Hypot(pt[0]-40, pt[1]); math.Abs(r-20) > 0.01 {
+					t.Errorf("baked circle vertex %v is not on the circle", pt)
+				}
+			}
+			continue
+		}
+		if len(g.Points) != len(w.Points) {
+			t.Errorf("baked segment %d is %+v, want %+v", i, g, w)
+			continue
+		}
+		for j := range w.Points {
+			if math.Hypot(g.Points[j][0]-w.Points[j][0], g.Points[j][1]-w.Points[j][1]) > 1e-9 {
+				t.Errorf("baked segment %d point %d is %v, want %v", i, j, g.Points[j], w.Points[j])
+			}
+		}
+	}
+}
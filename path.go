@@ -1,9 +1,9 @@
 package svger
 
 import (
+	"encoding/xml"
 	"fmt"
-	"log"
-	"strconv"
+	"strings"
 
 	gl "zappem.net/pub/graphics/svger/genericlexer"
 	mt "zappem.net/pub/graphics/svger/mtransform"
@@ -12,51 +12,49 @@ import (
 // Path is an SVG XML path element
 type Path struct {
 	ID              string `xml:"id,attr"`
+	Class           string `xml:"class,attr"`
 	D               string `xml:"d,attr"`
 	Style           string `xml:"style,attr"`
 	TransformString string `xml:"transform,attr"`
-	properties      map[string]string
-	StrokeWidth     float64 `xml:"stroke-width,attr"`
-	Fill            *string `xml:"fill,attr"`
-	Stroke          *string `xml:"stroke,attr"`
-	StrokeLineCap   *string `xml:"stroke-linecap,attr"`
-	StrokeLineJoin  *string `xml:"stroke-linejoin,attr"`
-	Segments        chan Segment
-	instructions    chan *DrawingInstruction
-	group           *Group
-}
-
-// A Segment of a path that contains a list of connected points, its
-// stroke Width and if the segment forms a closed loop.  Points are
-// defined in world space after any matrix transformation is applied.
-type Segment struct {
-	Width  float64
-	Closed bool
-	Points [][2]float64
-}
-
-func (p Path) newSegment(start [2]float64) *Segment {
-	var s Segment
-	s.Width = p.StrokeWidth * p.group.Owner.scale
-	s.Points = append(s.Points, start)
-	return &s
-}
-
-func (s *Segment) addPoint(p [2]float64) {
-	s.Points = append(s.Points, p)
+	// The style fields hold the computed style of the path once
+	// the image is parsed.
+	StrokeWidth    float64 `xml:"-"`
+	Fill           *string `xml:"fill,attr"`
+	Stroke         *string `xml:"stroke,attr"`
+	StrokeLineCap  *string `xml:"stroke-linecap,attr"`
+	StrokeLineJoin *string `xml:"stroke-linejoin,attr"`
+	// Segments is not used.
+	//
+	// Deprecated: use ParseSegments.
+	Segments     chan Segment
+	instructions chan *DrawingInstruction
+	group        *Group
+	attrs        []xml.Attr
 }
 
 type pathDescriptionParser struct {
-	p              *Path
-	lex            *gl.Lexer
-	x, y           float64
+	p   *Path
+	lex *gl.Lexer
+	// x, y is the current point in untransformed user space. All
+	// commands resolve relative coordinates against it, and only
+	// transform the resulting absolute coordinates.
+	x, y float64
+	// start is the initial point of the current subpath, in
+	// untransformed user space, which closing it returns to.
+	start          Tuple
 	currentcommand int
 	tokbuf         [4]gl.Item
 	peekcount      int
 	lasttuple      Tuple
 	transform      mt.Transform
 	svg            *Svg
-	currentsegment *Segment
+	// lastcommand is the upper case letter of the most recently
+	// parsed path command and lastcontrol is the untransformed
+	// final control point of the most recent curve. Together they
+	// support control point reflection for the smooth curve
+	// commands.
+	lastcommand string
+	lastcontrol Tuple
 }
 
 func newPathDParse() *pathDescriptionParser {
@@ -69,65 +67,74 @@ func newPathDParse() *pathDescriptionParser {
 // DrawingInstructions and errors. The former can be used to pass to a
 // path drawing library.
 func (p *Path) ParseDrawingInstructions() chan *DrawingInstruction {
-	p.parseStyle()
 	pdp := newPathDParse()
 	pdp.p = p
 	if p.group == nil {
 		p.group = new(Group)
 		temp := mt.Identity()
 		p.group.Transform = &temp
-	} else {
-		if lc := p.group.StrokeLineCap; lc != "" && p.StrokeLineCap == nil {
-			p.StrokeLineCap = &lc
-		}
-		if lj := p.group.StrokeLineJoin; lj != "" && p.StrokeLineJoin == nil {
-			p.StrokeLineJoin = &lj
-		}
 	}
+	cs := p.group.elementStyle("path", p.attrs)
 	pdp.svg = p.group.Owner
-	pathTransform := mt.Identity()
-	if p.TransformString != "" {
-		if pt, err := parseTransform(p.TransformString); err == nil {
-			pathTransform = pt
-		}
-	}
+	pathTransform, terr := parseTransform(p.TransformString)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, *p.group.Transform)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, pathTransform)
 
 	p.instructions = make(chan *DrawingInstruction, 100)
-	l, _ := gl.Lex(fmt.Sprint(p.ID), p.D)
+	l, _ := gl.LexPath(fmt.Sprint(p.ID), p.D)
 
 	pdp.lex = l
 	go func() {
 		defer close(p.instructions)
+		if terr != nil {
+			p.instructions <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
 		var count int
 		for {
 			i := pdp.lex.NextItem()
 			count++
 			switch {
 			case i.Type == gl.ItemError:
-				return
-			case i.Type == gl.ItemEOS:
-				scaledStrokeWidth := p.StrokeWidth * pdp.p.group.Owner.scale
 				pdp.p.instructions <- &DrawingInstruction{
-					Kind:           PaintInstruction,
-					StrokeWidth:    &scaledStrokeWidth,
-					Stroke:         p.Stroke,
-					StrokeLineCap:  p.StrokeLineCap,
-					StrokeLineJoin: p.StrokeLineJoin,
-					Fill:           p.Fill,
+					Kind:  ErrorInstruction,
+					Error: fmt.Errorf("error when parsing instruction number %d: %s", count, i.Value),
 				}
 				return
-			case i.Type == gl.ItemLetter:
-				if err := pdp.parseCommandDrawingInstructions(l, i); err != nil {
-					pdp.p.instructions <- &DrawingInstruction{
-						Kind:  ErrorInstruction,
-						Error: fmt.Errorf("error when parsing instruction number %d: %s", count, err),
+			case i.Type == gl.ItemEOS:
+				pdp.p.instructions <- cs.paintInstruction(p.group.strokeScale(&pdp.transform))
+				return
+			case i.Type == gl.ItemWSP || i.Type == gl.ItemComma:
+				// Separators between commands are optional.
+			case i.Type == gl.ItemLetter || i.Type == gl.ItemWord:
+				// Commands that take no arguments can be
+				// immediately followed by another command, so
+				// the lexer may merge several into one item.
+				cmds := strings.Split(i.Value, "")
+				for k, cmd := range cmds {
+					var err error
+					if k < len(cmds)-1 && cmd != "z" && cmd != "Z" {
+						err = fmt.Errorf("missing arguments for command %q", cmd)
+					} else {
+						err = pdp.parseCommandDrawingInstructions(cmd)
+					}
+					if err != nil {
+						pdp.p.instructions <- &DrawingInstruction{
+							Kind:  ErrorInstruction,
+							Error: fmt.Errorf("error when parsing instruction number %d: %s", count, err),
+						}
+						return
 					}
-					return
 				}
 			default:
-				fmt.Printf("Default invoked: %d item %v\n", count, i)
+				pdp.p.instructions <- &DrawingInstruction{
+					Kind:  ErrorInstruction,
+					Error: fmt.Errorf("error when parsing instruction number %d: unexpected %q", count, i.Value),
+				}
+				return
 			}
 		}
 	}()
@@ -137,196 +144,117 @@ func (p *Path) ParseDrawingInstructions() chan *DrawingInstruction {
 
 // parseCommandDrawingInstructions keys off a command letter and
 // performs specific further parsing of a path.
-func (pdp *pathDescriptionParser) parseCommandDrawingInstructions(l *gl.Lexer, i gl.Item) error {
-	switch i.Value {
-	case "M":
-		return pdp.parseMoveToAbsDI()
-	case "m":
-		return pdp.parseMoveToRelDI()
-	case "c":
-		return pdp.parseCurveToRelDI()
-	case "C":
-		return pdp.parseCurveToAbsDI()
-	case "l":
-		return pdp.parseLineToRelDI()
-	case "L":
-		return pdp.parseLineToAbsDI()
+func (pdp *pathDescriptionParser) parseCommandDrawingInstructions(cmd string) error {
+	if pdp.lastcommand == "" && cmd != "M" && cmd != "m" {
+		return fmt.Errorf("path data must start with a moveto command, not %q", cmd)
+	}
+	err := pdp.dispatchCommand(cmd)
+	pdp.lastcommand = strings.ToUpper(cmd)
+	return err
+}
+
+// dispatchCommand invokes the parser for a specific command letter.
+func (pdp *pathDescriptionParser) dispatchCommand(cmd string) error {
+	switch cmd {
+	case "M", "m":
+		return pdp.parseMoveToDI(cmd == "M")
+	case "C", "c":
+		return pdp.parseCurveToDI(cmd == "C")
+	case "S", "s":
+		return pdp.parseSmoothCurveToDI(cmd == "S")
+	case "Q", "q":
+		return pdp.parseQuadToDI(cmd == "Q")
+	case "T", "t":
+		return pdp.parseSmoothQuadToDI(cmd == "T")
+	case "L", "l":
+		return pdp.parseLineToDI(cmd == "L")
 	case "H", "h":
-		return pdp.parseHLineToDI(i.Value == "H")
+		return pdp.parseHLineToDI(cmd == "H")
 	case "V", "v":
-		return pdp.parseVLineToDI(i.Value == "V")
+		return pdp.parseVLineToDI(cmd == "V")
+	case "A", "a":
+		return pdp.parseArcToDI(cmd == "A")
 	case "z", "Z":
 		return pdp.parseCloseDI()
 	}
 
-	return fmt.Errorf("unknown command found in SVG: %s", i.Value)
+	return fmt.Errorf("unknown command found in SVG: %s", cmd)
 }
 
-func (pdp *pathDescriptionParser) parseMoveToAbsDI() error {
-	var tuples []Tuple
-
-	t, err := parseTuple(pdp.lex)
+// parseMoveToDI parses the M and m commands. Any coordinate pairs
+// following the first are implicit lineto commands of the same
+// (absolute or relative) form.
+func (pdp *pathDescriptionParser) parseMoveToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
 	if err != nil {
-		return fmt.Errorf("error parsing MoveToAbs. Expected tuple: %s", err)
-	}
-
-	// Update current cursor location with initial point.
-	pdp.x = t[0]
-	pdp.y = t[1]
-
-	if pdp.p.group.Owner == nil {
-		pdp.p.group.Owner = &Svg{scale: 1}
+		return fmt.Errorf("error parsing MoveTo: %v", err)
 	}
-	if pdp.p.StrokeWidth == -1 {
-		pdp.p.StrokeWidth = 1
-	}
-
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		t, err := parseTuple(pdp.lex)
-		if err != nil {
-			return fmt.Errorf("Error Passing MoveToAbs\n%s", err)
-		}
-		tuples = append(tuples, t)
-		pdp.lex.ConsumeWhiteSpace()
+	if len(tuples) == 0 {
+		return fmt.Errorf("MoveTo requires a coordinate")
 	}
 
+	// Update current cursor location with initial point.
+	t := pdp.relative(abs, tuples[0])
+	pdp.x, pdp.y = t[0], t[1]
+	pdp.start = t
 	x, y := pdp.transform.Apply(pdp.x, pdp.y)
 	pdp.p.instructions <- &DrawingInstruction{Kind: MoveInstruction, M: &Tuple{x, y}}
 
-	for _, nt := range tuples {
-		pdp.x = nt[0]
-		pdp.y = nt[1]
-		x, y = pdp.transform.Apply(pdp.x, pdp.y)
-		pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
-	}
-
+	pdp.lineTo(abs, tuples[1:])
 	return nil
 }
 
-func (pdp *pathDescriptionParser) parseLineToAbsDI() error {
-	var tuples []Tuple
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		t, err := parseTuple(pdp.lex)
-		if err != nil {
-			return fmt.Errorf("Error Passing LineToAbs\n%s", err)
-		}
-		tuples = append(tuples, t)
-		pdp.lex.ConsumeWhiteSpace()
+// parseLineToDI parses the L and l commands.
+func (pdp *pathDescriptionParser) parseLineToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
+	if err != nil {
+		return fmt.Errorf("error parsing LineTo: %v", err)
 	}
-	if len(tuples) > 0 {
-		x, y := pdp.transform.Apply(pdp.x, pdp.y)
-
-		for _, nt := range tuples {
-			pdp.x = nt[0]
-			pdp.y = nt[1]
-			x, y = pdp.transform.Apply(pdp.x, pdp.y)
-			pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
-		}
+	if len(tuples) == 0 {
+		return fmt.Errorf("LineTo requires a coordinate")
 	}
-
+	pdp.lineTo(abs, tuples)
 	return nil
 }
 
-func (pdp *pathDescriptionParser) parseMoveToRelDI() error {
-	pdp.lex.ConsumeWhiteSpace()
-	t, err := parseTuple(pdp.lex)
-	if err != nil {
-		return fmt.Errorf("Error Passing MoveToRel Expected First Tuple %s", err)
-	}
-
-	pdp.x += t[0]
-	pdp.y += t[1]
-
-	var tuples []Tuple
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		t, err := parseTuple(pdp.lex)
-		if err != nil {
-			return fmt.Errorf("Error Passing MoveToRel\n%s", err)
-		}
-		tuples = append(tuples, t)
-		pdp.lex.ConsumeWhiteSpace()
-	}
-
-	x, y := pdp.transform.Apply(pdp.x, pdp.y)
-	pdp.p.instructions <- &DrawingInstruction{Kind: MoveInstruction, M: &Tuple{x, y}}
-
+// lineTo emits a LineInstruction for each of the tuples.
+func (pdp *pathDescriptionParser) lineTo(abs bool, tuples []Tuple) {
 	for _, nt := range tuples {
-		pdp.x += nt[0]
-		pdp.y += nt[1]
-		x, y = pdp.transform.Apply(pdp.x, pdp.y)
+		t := pdp.relative(abs, nt)
+		pdp.x, pdp.y = t[0], t[1]
+		x, y := pdp.transform.Apply(pdp.x, pdp.y)
 		pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
 	}
-
-	return nil
 }
 
 func (pdp *pathDescriptionParser) parseHLineToDI(abs bool) error {
-	coords := []float64{}
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		item := pdp.lex.NextItem()
-		c, err := strconv.ParseFloat(item.Value, 64)
-		if err != nil {
-			return fmt.Errorf("parsing %q: %s", item.Value, err)
-		}
-		coords = append(coords, c)
-		pdp.lex.ConsumeWhiteSpace()
+	coords, err := pdp.parseNumberList()
+	if err != nil {
+		return fmt.Errorf("error parsing HLineTo: %v", err)
 	}
-	if len(coords) > 0 {
-		for _, c := range coords {
-			if abs {
-				pdp.x = c
-			} else {
-				pdp.x += c
-			}
-			x, y := pdp.transform.Apply(pdp.x, pdp.y)
-			pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
-		}
+	if len(coords) == 0 {
+		return fmt.Errorf("HLineTo requires a coordinate")
 	}
-	return nil
-}
-
-func (pdp *pathDescriptionParser) parseLineToRelDI() error {
-	var tuples []Tuple
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		t, err := parseTuple(pdp.lex)
-		if err != nil {
-			return fmt.Errorf("Error Passing LineToRel\n%s", err)
+	for _, c := range coords {
+		if abs {
+			pdp.x = c
+		} else {
+			pdp.x += c
 		}
-		tuples = append(tuples, t)
-		pdp.lex.ConsumeWhiteSpace()
-	}
-
-	if len(tuples) > 0 {
 		x, y := pdp.transform.Apply(pdp.x, pdp.y)
-
-		for _, nt := range tuples {
-			pdp.x += nt[0]
-			pdp.y += nt[1]
-			x, y = pdp.transform.Apply(pdp.x, pdp.y)
-			pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
-		}
+		pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
 	}
-
 	return nil
 }
 
 func (pdp *pathDescriptionParser) parseVLineToDI(abs bool) error {
-	pdp.lex.ConsumeWhiteSpace()
-	coords := []float64{}
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		n, err := parseNumber(pdp.lex.NextItem())
-		if err != nil {
-			return fmt.Errorf("Error Passing VLineToRel\n%s", err)
-		}
-		coords = append(coords, n)
-		pdp.lex.ConsumeWhiteSpace()
+	coords, err := pdp.parseNumberList()
+	if err != nil {
+		return fmt.Errorf("error parsing VLineTo: %v", err)
+	}
+	if len(coords) == 0 {
+		return fmt.Errorf("VLineTo requires a coordinate")
 	}
-
 	for _, n := range coords {
 		if abs {
 			pdp.y = n
@@ -336,125 +264,235 @@ func (pdp *pathDescriptionParser) parseVLineToDI(abs bool) error {
 		x, y := pdp.transform.Apply(pdp.x, pdp.y)
 		pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
 	}
-
 	return nil
 }
 
 func (pdp *pathDescriptionParser) parseCloseDI() error {
 	pdp.lex.ConsumeWhiteSpace()
-	pdp.p.instructions <- &DrawingInstruction{Kind: CloseInstruction}
+	pdp.x, pdp.y = pdp.start[0], pdp.start[1]
+	x, y := pdp.transform.Apply(pdp.x, pdp.y)
+	pdp.p.instructions <- &DrawingInstruction{Kind: CloseInstruction, M: &Tuple{x, y}}
+	return nil
+}
+
+// parseCurveToDI parses the C and c commands. The control and end
+// points of a relative curve are all offsets from the current point
+// at the start of that curve.
+func (pdp *pathDescriptionParser) parseCurveToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
+	if err != nil {
+		return fmt.Errorf("error parsing CurveTo: %v", err)
+	}
+	if len(tuples) == 0 || len(tuples)%3 != 0 {
+		return fmt.Errorf("CurveTo requires triples of coordinates, got %d", len(tuples))
+	}
+	for j := 0; j < len(tuples); j += 3 {
+		c1 := pdp.relative(abs, tuples[j])
+		c2 := pdp.relative(abs, tuples[j+1])
+		t := pdp.relative(abs, tuples[j+2])
+		pdp.emitCurve(c1, c2, t)
+		pdp.lastcommand = "C"
+		pdp.lastcontrol = c2
+	}
 	return nil
 }
 
-func (pdp *pathDescriptionParser) parseCurveToRelDI() error {
+// parseTupleList parses all of the consecutive coordinate pairs
+// that follow a path command.
+func (pdp *pathDescriptionParser) parseTupleList() ([]Tuple, error) {
 	var tuples []Tuple
 	pdp.lex.ConsumeWhiteSpace()
 	for pdp.lex.PeekItem().Type == gl.ItemNumber {
 		t, err := parseTuple(pdp.lex)
 		if err != nil {
-			return fmt.Errorf("Error Passing CurveToRel\n%s", err)
+			return nil, err
 		}
 		tuples = append(tuples, t)
 		pdp.lex.ConsumeWhiteSpace()
+		pdp.lex.ConsumeComma()
+		pdp.lex.ConsumeWhiteSpace()
 	}
-	x, y := pdp.transform.Apply(pdp.x, pdp.y)
+	return tuples, nil
+}
 
-	for j := 0; j < len(tuples)/3; j++ {
-		c1x, c1y := pdp.transform.Apply(x+tuples[j*3][0], y+tuples[j*3][1])
-		c2x, c2y := pdp.transform.Apply(x+tuples[j*3+1][0], y+tuples[j*3+1][1])
-		tx, ty := pdp.transform.Apply(x+tuples[j*3+2][0], y+tuples[j*3+2][1])
-
-		pdp.p.instructions <- &DrawingInstruction{
-			Kind: CurveInstruction,
-			CurvePoints: &CurvePoints{C1: &Tuple{c1x, c1y},
-				C2: &Tuple{c2x, c2y},
-				T:  &Tuple{tx, ty},
-			},
+// parseNumberList parses all of the consecutive numbers that follow
+// a path command.
+func (pdp *pathDescriptionParser) parseNumberList() ([]float64, error) {
+	var nums []float64
+	for {
+		for pdp.lex.PeekItem().Type == gl.ItemWSP || pdp.lex.PeekItem().Type == gl.ItemComma {
+			pdp.lex.NextItem()
 		}
-
-		pdp.x += tuples[j*3+2][0]
-		pdp.y += tuples[j*3+2][1]
-		x, y = pdp.transform.Apply(pdp.x, pdp.y)
+		if pdp.lex.PeekItem().Type != gl.ItemNumber {
+			return nums, nil
+		}
+		n, err := parseNumber(pdp.lex.NextItem())
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, n)
 	}
-
-	return nil
 }
 
-func (pdp *pathDescriptionParser) parseCurveToAbsDI() error {
-	var (
-		tuples      []Tuple
-		instrTuples []Tuple
-	)
-
-	pdp.lex.ConsumeWhiteSpace()
-	for pdp.lex.PeekItem().Type == gl.ItemNumber {
-		t, err := parseTuple(pdp.lex)
+// parseArcArgs parses all of the consecutive groups of elliptical
+// arc arguments that follow an A or a command. The large-arc and
+// sweep flags of each group must be ItemFlag tokens.
+func (pdp *pathDescriptionParser) parseArcArgs() ([]float64, error) {
+	var nums []float64
+	for {
+		for pdp.lex.PeekItem().Type == gl.ItemWSP || pdp.lex.PeekItem().Type == gl.ItemComma {
+			pdp.lex.NextItem()
+		}
+		want := gl.ItemNumber
+		if k := len(nums) % 7; k == 3 || k == 4 {
+			want = gl.ItemFlag
+		}
+		switch i := pdp.lex.PeekItem(); {
+		case i.Type == want:
+		case len(nums)%7 == 0:
+			return nums, nil
+		case want == gl.ItemFlag && i.Type == gl.ItemNumber:
+			return nil, fmt.Errorf("ArcTo flag must be 0 or 1, got %s", i.Value)
+		default:
+			return nil, fmt.Errorf("ArcTo requires groups of 7 numbers, got %d", len(nums))
+		}
+		n, err := parseNumber(pdp.lex.NextItem())
 		if err != nil {
-			return fmt.Errorf("Error parsing CurveToRel\n%s", err)
+			return nil, err
 		}
-		tuples = append(tuples, t)
-		pdp.lex.ConsumeWhiteSpace()
-		pdp.lex.ConsumeComma()
+		nums = append(nums, n)
 	}
+}
 
-	for j := 0; j < len(tuples)/3; j++ {
-		for _, nt := range tuples[j*3 : (j+1)*3] {
-			pdp.x = nt[0]
-			pdp.y = nt[1]
+// relative converts t into an absolute user space coordinate when
+// abs is false.
+func (pdp *pathDescriptionParser) relative(abs bool, t Tuple) Tuple {
+	if abs {
+		return t
+	}
+	return Tuple{pdp.x + t[0], pdp.y + t[1]}
+}
 
-			tx, ty := pdp.transform.Apply(pdp.x, pdp.y)
-			instrTuples = append(instrTuples, Tuple{tx, ty})
+// reflectedControl returns the reflection of the last control point
+// about the current point when the previous command was one of
+// kinds. Otherwise, the current point itself is returned.
+func (pdp *pathDescriptionParser) reflectedControl(kinds ...string) Tuple {
+	for _, k := range kinds {
+		if pdp.lastcommand == k {
+			return Tuple{2*pdp.x - pdp.lastcontrol[0], 2*pdp.y - pdp.lastcontrol[1]}
 		}
+	}
+	return Tuple{pdp.x, pdp.y}
+}
+
+// emitCurve transforms the untransformed user space control points
+// of a cubic Bézier curve and emits them as a CurveInstruction. The
+// current point is advanced to t.
+func (pdp *pathDescriptionParser) emitCurve(c1, c2, t Tuple) {
+	pdp.p.instructions <- curveInstruction(&pdp.transform, [3]Tuple{c1, c2, t})
+	pdp.x, pdp.y = t[0], t[1]
+}
 
-		pdp.p.instructions <- &DrawingInstruction{
-			Kind: CurveInstruction,
-			CurvePoints: &CurvePoints{
-				C1: &instrTuples[0],
-				C2: &instrTuples[1],
-				T:  &instrTuples[2],
-			},
+// parseSmoothCurveToDI parses the S and s commands. The first control
+// point is the reflection of the second control point of a preceding
+// cubic curve, or the current point if there is no such curve.
+func (pdp *pathDescriptionParser) parseSmoothCurveToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
+	if err != nil {
+		return fmt.Errorf("error parsing SmoothCurveTo: %v", err)
+	}
+	if len(tuples) == 0 || len(tuples)%2 != 0 {
+		return fmt.Errorf("SmoothCurveTo requires pairs of coordinates, got %d", len(tuples))
+	}
+	for j := 0; j < len(tuples); j += 2 {
+		c1 := pdp.reflectedControl("C", "S")
+		c2 := pdp.relative(abs, tuples[j])
+		t := pdp.relative(abs, tuples[j+1])
+		pdp.emitCurve(c1, c2, t)
+		pdp.lastcommand = "C"
+		pdp.lastcontrol = c2
+	}
+	return nil
+}
+
+// parseArcToDI parses the A and a commands. Each elliptical arc is
+// emitted as a sequence of cubic Bézier curves.
+func (pdp *pathDescriptionParser) parseArcToDI(abs bool) error {
+	nums, err := pdp.parseArcArgs()
+	if err != nil {
+		return fmt.Errorf("error parsing ArcTo: %v", err)
+	}
+	if len(nums) == 0 {
+		return fmt.Errorf("ArcTo requires groups of 7 numbers, got 0")
+	}
+	for j := 0; j < len(nums); j += 7 {
+		a := nums[j : j+7]
+		t := pdp.relative(abs, Tuple{a[5], a[6]})
+		curves, ok := arcToCubics(Tuple{pdp.x, pdp.y}, a[0], a[1], a[2], a[3] == 1, a[4] == 1, t)
+		if !ok {
+			pdp.x, pdp.y = t[0], t[1]
+			x, y := pdp.transform.Apply(pdp.x, pdp.y)
+			pdp.p.instructions <- &DrawingInstruction{Kind: LineInstruction, M: &Tuple{x, y}}
+			continue
+		}
+		for _, c := range curves {
+			pdp.emitCurve(c[0], c[1], c[2])
 		}
 	}
+	return nil
+}
+
+// emitQuad emits a quadratic Bézier curve, from the current point to
+// t with control point q, as its exact cubic equivalent.
+func (pdp *pathDescriptionParser) emitQuad(q, t Tuple) {
+	c1 := Tuple{pdp.x + 2.0/3.0*(q[0]-pdp.x), pdp.y + 2.0/3.0*(q[1]-pdp.y)}
+	c2 := Tuple{t[0] + 2.0/3.0*(q[0]-t[0]), t[1] + 2.0/3.0*(q[1]-t[1])}
+	pdp.emitCurve(c1, c2, t)
+	pdp.lastcommand = "Q"
+	pdp.lastcontrol = q
+}
 
+// parseQuadToDI parses the Q and q commands.
+func (pdp *pathDescriptionParser) parseQuadToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
+	if err != nil {
+		return fmt.Errorf("error parsing QuadTo: %v", err)
+	}
+	if len(tuples) == 0 || len(tuples)%2 != 0 {
+		return fmt.Errorf("QuadTo requires pairs of coordinates, got %d", len(tuples))
+	}
+	for j := 0; j < len(tuples); j += 2 {
+		q := pdp.relative(abs, tuples[j])
+		t := pdp.relative(abs, tuples[j+1])
+		pdp.emitQuad(q, t)
+	}
 	return nil
 }
 
-// pathStyle parses the path style string and converts it into
-// path properties.
-func (p *Path) parseStyle() {
-	p.properties = splitStyle(p.Style)
-	suppressStroke := false
-	suppressFill := false
-	for key, val := range p.properties {
-		switch key {
-		case "fill":
-			p.Fill = refString(val)
-		case "fill-opacity":
-			if v := parseDecimal(val); v == 0 {
-				suppressFill = true
-			}
-		case "stroke":
-			p.Stroke = refString(val)
-		case "stroke-linecap":
-			p.StrokeLineCap = refString(val)
-		case "stroke-linejoin":
-			p.StrokeLineJoin = refString(val)
-		case "stroke-opacity":
-			if v := parseDecimal(val); v == 0 {
-				suppressStroke = true
-			}
-		case "stroke-width":
-			p.StrokeWidth = parseDecimal(val)
-		default:
-			if Debug {
-				log.Printf("TODO parse %q property %q", key, val)
-			}
-		}
+// parseSmoothQuadToDI parses the T and t commands. The control point
+// is the reflection of the control point of a preceding quadratic
+// curve, or the current point if there is no such curve.
+func (pdp *pathDescriptionParser) parseSmoothQuadToDI(abs bool) error {
+	tuples, err := pdp.parseTupleList()
+	if err != nil {
+		return fmt.Errorf("error parsing SmoothQuadTo: %v", err)
 	}
-	if suppressFill {
-		p.Fill = refString("none")
+	if len(tuples) == 0 {
+		return fmt.Errorf("SmoothQuadTo requires a coordinate")
 	}
-	if suppressStroke {
-		p.Stroke = refString("none")
+	for _, nt := range tuples {
+		q := pdp.reflectedControl("Q", "T")
+		t := pdp.relative(abs, nt)
+		pdp.emitQuad(q, t)
 	}
+	return nil
+}
+
+// updateStyle sets the exported style fields of the path to their
+// computed values.
+func (p *Path) updateStyle() {
+	cs := p.group.elementStyle("path", p.attrs)
+	p.StrokeWidth = cs.StrokeWidth
+	p.Fill, p.Stroke = &cs.Fill, &cs.Stroke
+	p.StrokeLineCap, p.StrokeLineJoin = &cs.StrokeLineCap, &cs.StrokeLineJoin
 }
@@ -1,7 +1,13 @@
 package svger
 
 import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+
+	gl "zappem.net/pub/graphics/svger/genericlexer"
 )
 
 type PathTest struct {
@@ -98,3 +104,828 @@ func TestParsePathList(t *testing.T) {
 		}
 	}
 }
+
+type CurveTest struct {
+	Description string
+	Svg         string
+	Curves      []CurvePoints
+}
+
+var curveTests = []CurveTest{
+	{
+		"absolute quadratic",
+		`<svg><path d="M0 0 Q30 30 60 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{20, 20}, C2: &Tuple{40, 20}, T: &Tuple{60, 0}},
+		},
+	},
+	{
+		"relative quadratic",
+		`<svg><path d="M10 10 q30 30 60 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{30, 30}, C2: &Tuple{50, 30}, T: &Tuple{70, 10}},
+		},
+	},
+	{
+		"smooth quadratic",
+		`<svg><path d="M0 0 Q30 30 60 0 T120 0 t60 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{20, 20}, C2: &Tuple{40, 20}, T: &Tuple{60, 0}},
+			{C1: &Tuple{80, -20}, C2: &Tuple{100, -20}, T: &Tuple{120, 0}},
+			{C1: &Tuple{140, 20}, C2: &Tuple{160, 20}, T: &Tuple{180, 0}},
+		},
+	},
+	{
+		"smooth quadratic without predecessor",
+		`<svg><path d="M0 0 L30 0 T90 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{30, 0}, C2: &Tuple{50, 0}, T: &Tuple{90, 0}},
+		},
+	},
+	{
+		"smooth cubic",
+		`<svg><path d="M0 0 C0 10 20 10 20 0 S40 -10 40 0 s20 10 20 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{0, 10}, C2: &Tuple{20, 10}, T: &Tuple{20, 0}},
+			{C1: &Tuple{20, -10}, C2: &Tuple{40, -10}, T: &Tuple{40, 0}},
+			{C1: &Tuple{40, 10}, C2: &Tuple{60, 10}, T: &Tuple{60, 0}},
+		},
+	},
+	{
+		"smooth cubic after relative cubic",
+		`<svg><path d="M10 0 c0 10 20 10 20 0 s20 -10 20 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{10, 10}, C2: &Tuple{30, 10}, T: &Tuple{30, 0}},
+			{C1: &Tuple{30, -10}, C2: &Tuple{50, -10}, T: &Tuple{50, 0}},
+		},
+	},
+	{
+		"smooth cubic without predecessor",
+		`<svg><path d="M0 0 S20 10 20 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{0, 0}, C2: &Tuple{20, 10}, T: &Tuple{20, 0}},
+		},
+	},
+	{
+		"quarter arc",
+		`<svg><path d="M10 0 A10 10 0 0 1 0 10"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{10, kappa * 10}, C2: &Tuple{kappa * 10, 10}, T: &Tuple{0, 10}},
+		},
+	},
+	{
+		"relative half arc",
+		`<svg><path d="M0 0 a10 10 0 0 0 20 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{0, kappa * 10}, C2: &Tuple{10 - kappa*10, 10}, T: &Tuple{10, 10}},
+			{C1: &Tuple{10 + kappa*10, 10}, C2: &Tuple{20, kappa * 10}, T: &Tuple{20, 0}},
+		},
+	},
+	{
+		"scaled up radii",
+		`<svg><path d="M0 0 A1 1 0 0 1 20 0"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{0, -kappa * 10}, C2: &Tuple{10 - kappa*10, -10}, T: &Tuple{10, -10}},
+			{C1: &Tuple{10 + kappa*10, -10}, C2: &Tuple{20, -kappa * 10}, T: &Tuple{20, 0}},
+		},
+	},
+	{
+		"rotated elliptical arc",
+		`<svg><path d="M0 0 A20 10 90 0 1 0 40"/></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{kappa * 10, 0}, C2: &Tuple{10, 20 - kappa*20}, T: &Tuple{10, 20}},
+			{C1: &Tuple{10, 20 + kappa*20}, C2: &Tuple{kappa * 10, 40}, T: &Tuple{0, 40}},
+		},
+	},
+	{
+		"relative cubic in a scaled group",
+		`<svg><g transform="scale(2)"><path d="M1 1 c1 0 2 0 3 0 c0 1 0 2 0 3"/></g></svg>`,
+		[]CurvePoints{
+			{C1: &Tuple{4, 2}, C2: &Tuple{6, 2}, T: &Tuple{8, 2}},
+			{C1: &Tuple{8, 4}, C2: &Tuple{8, 6}, T: &Tuple{8, 8}},
+		},
+	},
+}
+
+// kappa is the control point distance for a unit quarter circle.
+var kappa = 4.0 / 3.0 * (math.Sqrt2 - 1)
+
+// closeTuple confirms two tuples are within rounding error of each
+// other.
+func closeTuple(a, b *Tuple) bool {
+	const eps = 1e-9
+	return math.Abs(a[0]-b[0]) < eps && math.Abs(a[1]-b[1]) < eps
+}
+
+func TestParseCurves(t *testing.T) {
+	for _, test := range curveTests {
+		svg, err := ParseSvg(test.Svg, "test", 0)
+		if err != nil {
+			t.Fatalf("ParseSvg failed for test %s: %v", test.Description, err)
+		}
+		var curves []*CurvePoints
+		for di := range svg.ParseDrawingInstructions() {
+			if di.Error != nil {
+				t.Fatalf("test %s failed: %v", test.Description, di.Error)
+			}
+			if di.Kind == CurveInstruction {
+				curves = append(curves, di.CurvePoints)
+			}
+		}
+		if len(curves) != len(test.Curves) {
+			t.Fatalf("expected %d curves for test %s, but received %d", len(test.Curves), test.Description, len(curves))
+		}
+		for i, want := range test.Curves {
+			got := curves[i]
+			if !closeTuple(got.C1, want.C1) || !closeTuple(got.C2, want.C2) || !closeTuple(got.T, want.T) {
+				t.Errorf("test %s curve %d: got C1=%v C2=%v T=%v, want C1=%v C2=%v T=%v", test.Description, i, *got.C1, *got.C2, *got.T, *want.C1, *want.C2, *want.T)
+			}
+		}
+	}
+}
+
+type ConformanceTest struct {
+	D string
+	// Kinds lists the expected instruction kinds, and Points the
+	// expected end points of each Move, Line and Curve
+	// instruction. Both are ignored if the path data is expected
+	// to Fail.
+	Kinds  []InstructionType
+	Points []Tuple
+	Fail   bool
+}
+
+var conformanceTests = []ConformanceTest{
+	{D: "", Kinds: []InstructionType{PaintInstruction}},
+	{
+		D:      " M 10,20 L 30,40 ",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{10, 20}, {30, 40}},
+	},
+	{
+		D:      "M0,0L10,0,10,10",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {10, 10}},
+	},
+	{
+		D:      "M10 20 30 40 50 60",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{10, 20}, {30, 40}, {50, 60}},
+	},
+	{
+		D:      "m10 20 30 40 50 60",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{10, 20}, {40, 60}, {90, 120}},
+	},
+	{
+		D:      "M0 0 C 0 10 10 10 10 0 10 -10 20 -10 20 0",
+		Kinds:  []InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {20, 0}},
+	},
+	{
+		D:      "M0 0 c 0 10 10 10 10 0 0 -10 10 -10 10 0",
+		Kinds:  []InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {20, 0}},
+	},
+	{
+		D:      "M0 0 H10 20 V10 h-10 v-10 -5",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, LineInstruction, LineInstruction, LineInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {20, 0}, {20, 10}, {10, 10}, {10, 0}, {10, -5}},
+	},
+	{
+		D:      "M0 0 10 0zM5 5 10 0Z",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, CloseInstruction, MoveInstruction, LineInstruction, CloseInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {5, 5}, {10, 0}},
+	},
+	{
+		D:      "M0 0 Q 5 5 10 0 15 -5 20 0",
+		Kinds:  []InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}, {20, 0}},
+	},
+	{
+		D:      "M0 0 A 5 5 0 0 1 10 0 5 5 0 0 1 20 0",
+		Kinds:  []InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {5, -5}, {10, 0}, {15, -5}, {20, 0}},
+	},
+	{
+		D:      "M0 0 A 0 5 0 0 1 10 0",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, 0}},
+	},
+	{
+		D:      "M1.5.5-2e-3-1L.5.5E1 1-2",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, LineInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{1.5, 0.5}, {-2e-3, -1}, {0.5, 5}, {1, -2}},
+	},
+	{
+		D:      "M0,0\r\n\tl+10-.5e1",
+		Kinds:  []InstructionType{MoveInstruction, LineInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {10, -5}},
+	},
+	{
+		D:      "M0 0a5 5 0 0110 0 5 5 0 1110 0",
+		Kinds:  []InstructionType{MoveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, CurveInstruction, PaintInstruction},
+		Points: []Tuple{{0, 0}, {5, -5}, {10, 0}, {15, -5}, {20, 0}},
+	},
+	{D: "M0 0 L1e 2", Fail: true},
+	{D: "M0 0 L. 2", Fail: true},
+	{D: "M0 0 L1 2 #", Fail: true},
+	{D: "L10 10", Fail: true},
+	{D: "M10", Fail: true},
+	{D: "M0 0 L", Fail: true},
+	{D: "M0 0 L10", Fail: true},
+	{D: "M0 0 L1,,2", Fail: true},
+	{D: "M0 0 Z 10", Fail: true},
+	{D: "M0 0 C1 1 2 2", Fail: true},
+	{D: "M0 0 S1 1", Fail: true},
+	{D: "M0 0 X1 1", Fail: true},
+	{D: "M0 0 A1 1 0 2 0 5 5", Fail: true},
+	{D: "M0 0 a5 5 0 0.5 10 0", Fail: true},
+	{D: "M0 0 A1 1 0 1 0 5", Fail: true},
+	{D: "M0 0 LZ", Fail: true},
+}
+
+// pathInstructions returns all of the instructions generated for a
+// single path with path data, d.
+func pathInstructions(t *testing.T, d string) []*DrawingInstruction {
+	t.Helper()
+	svg, err := ParseSvg(`<svg><path d="`+d+`"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed for %q: %v", d, err)
+	}
+	var dis []*DrawingInstruction
+	for di := range svg.ParseDrawingInstructions() {
+		dis = append(dis, di)
+	}
+	return dis
+}
+
+// endPoint returns the point an instruction draws to, or nil if it
+// doesn't draw to one.
+func endPoint(di *DrawingInstruction) *Tuple {
+	switch di.Kind {
+	case MoveInstruction, LineInstruction:
+		return di.M
+	case CurveInstruction:
+		return di.CurvePoints.T
+	}
+	return nil
+}
+
+func TestPathConformance(t *testing.T) {
+	for _, test := range conformanceTests {
+		dis := pathInstructions(t, test.D)
+		if test.Fail {
+			if n := len(dis); n == 0 || dis[n-1].Kind != ErrorInstruction {
+				t.Errorf("path %q: expected an error, got %d instructions", test.D, n)
+			}
+			continue
+		}
+		if len(dis) != len(test.Kinds) {
+			t.Errorf("path %q: expected %d instructions, got %d", test.D, len(test.Kinds), len(dis))
+			continue
+		}
+		var points []*Tuple
+		for i, di := range dis {
+			if di.Kind != test.Kinds[i] {
+				t.Errorf("path %q: instruction %d is %v, expected %v", test.D, i, di.Kind, test.Kinds[i])
+			}
+			if pt := endPoint(di); pt != nil {
+				points = append(points, pt)
+			}
+		}
+		if len(points) != len(test.Points) {
+			t.Errorf("path %q: expected %d points, got %d", test.D, len(test.Points), len(points))
+			continue
+		}
+		for i, pt := range points {
+			if !closeTuple(pt, &test.Points[i]) {
+				t.Errorf("path %q: point %d is %v, expected %v", test.D, i, *pt, test.Points[i])
+			}
+		}
+	}
+}
+
+// relativeTests pairs path data using absolute commands with the
+// same path written with relative commands.
+var relativeTests = [][2]string{
+	{"M10,10 L20,10 H30 V20 Z", "m10,10 l10,0 h10 v10 z"},
+	{"M1,1 2,2 3,3", "m1,1 1,1 1,1"},
+	{"M1,1 L2,2 M5,5 L6,6", "m1,1 l1,1 m3,3 l1,1"},
+	{"M10,10 C20,0 30,0 40,10 C50,20 60,20 70,10", "m10,10 c10,-10 20,-10 30,0 10,10 20,10 30,0"},
+	{"M0,0 C0,10 10,10 10,0 S20,-10 20,0 S30,10 30,0", "m0,0 c0,10 10,10 10,0 s10,-10 10,0 s10,10 10,0"},
+	{"M0,0 Q5,10 10,0 T20,0 T30,0", "m0,0 q5,10 10,0 t10,0 t10,0"},
+	{"M0,0 A5,5 0 0,1 10,0 A5,10 30 1,0 20,5", "m0,0 a5,5 0 0,1 10,0 a5,10 30 1,0 10,5"},
+	{"M10,10 L20,10 L20,20 Z M15,15 L16,15 Z L10,20", "m10,10 l10,0 l0,10 z m5,5 l1,0 z l-5,5"},
+}
+
+// sameInstruction confirms two drawing instructions are equivalent to
+// within rounding error.
+func sameInstruction(a, b *DrawingInstruction) bool {
+	if a.Kind != b.Kind || (a.M == nil) != (b.M == nil) || (a.CurvePoints == nil) != (b.CurvePoints == nil) {
+		return false
+	}
+	if a.M != nil && !closeTuple(a.M, b.M) {
+		return false
+	}
+	if a.CurvePoints != nil {
+		ac, bc := a.CurvePoints, b.CurvePoints
+		return closeTuple(ac.C1, bc.C1) && closeTuple(ac.C2, bc.C2) && closeTuple(ac.T, bc.T)
+	}
+	return true
+}
+
+func TestRelativeMatchesAbsolute(t *testing.T) {
+	transforms := []string{
+		"",
+		"translate(3,4)",
+		"scale(2,-3)",
+		"rotate(30) skewX(10)",
+		"matrix(0.5 1 -2 0.25 7 -9)",
+	}
+	for _, test := range relativeTests {
+		for _, tr := range transforms {
+			var dis [2][]*DrawingInstruction
+			for i, d := range test {
+				// Apply the transform both to a group and to
+				// the path itself.
+				src := `<svg><g transform="` + tr + `"><path transform="` + tr + `" d="` + d + `"/></g></svg>`
+				svg, err := ParseSvg(src, "test", 0)
+				if err != nil {
+					t.Fatalf("ParseSvg failed for %q: %v", src, err)
+				}
+				for di := range svg.ParseDrawingInstructions() {
+					if di.Error != nil {
+						t.Fatalf("path %q with transform %q failed: %v", d, tr, di.Error)
+					}
+					dis[i] = append(dis[i], di)
+				}
+			}
+			if len(dis[0]) != len(dis[1]) {
+				t.Errorf("paths %q and %q with transform %q: got %d and %d instructions", test[0], test[1], tr, len(dis[0]), len(dis[1]))
+				continue
+			}
+			for j := range dis[0] {
+				if !sameInstruction(dis[0][j], dis[1][j]) {
+					t.Errorf("paths %q and %q with transform %q: instruction %d differs: %v vs %v", test[0], test[1], tr, j, dis[0][j], dis[1][j])
+				}
+			}
+		}
+	}
+}
+
+func TestCloseTarget(t *testing.T) {
+	vs := []struct {
+		svg string
+		// targets lists the expected M of each CloseInstruction.
+		targets []Tuple
+		// ends lists the expected end point of the instruction
+		// following each CloseInstruction, if any.
+		ends []Tuple
+	}{
+		{
+			svg:     `<svg><path d="M10,10 l10,0 l0,10 z l0,5"/></svg>`,
+			targets: []Tuple{{10, 10}},
+			ends:    []Tuple{{10, 15}},
+		},
+		{
+			svg:     `<svg><path d="M1,1 h2 v2 z m5,0 h1 z"/></svg>`,
+			targets: []Tuple{{1, 1}, {6, 1}},
+			ends:    []Tuple{{6, 1}},
+		},
+		{
+			svg:     `<svg><g transform="translate(5,0) scale(2)"><path d="M1,1 h2 v2 z l1,0"/></g></svg>`,
+			targets: []Tuple{{7, 2}},
+			ends:    []Tuple{{9, 2}},
+		},
+		{
+			svg:     `<svg><rect x="1" y="2" width="3" height="4"/><rect x="1" y="2" width="3" height="4" rx="1"/></svg>`,
+			targets: []Tuple{{1, 2}, {2, 2}},
+		},
+		{
+			svg:     `<svg><polygon points="3,4 5,6 7,4"/><ellipse cx="1" cy="1" rx="2" ry="1"/></svg>`,
+			targets: []Tuple{{3, 4}, {3, 1}},
+		},
+	}
+	for i, v := range vs {
+		svg, err := ParseSvg(v.svg, "test", 0)
+		if err != nil {
+			t.Fatalf("test=%d: ParseSvg failed: %v", i, err)
+		}
+		var dis []*DrawingInstruction
+		for di := range svg.ParseDrawingInstructions() {
+			dis = append(dis, di)
+		}
+		var targets, ends []Tuple
+		for j, di := range dis {
+			if di.Kind != CloseInstruction {
+				continue
+			}
+			if di.M == nil {
+				t.Errorf("test=%d: close instruction %d has no target", i, j)
+				continue
+			}
+			targets = append(targets, *di.M)
+			if j+1 < len(dis) {
+				if pt := endPoint(dis[j+1]); pt != nil {
+					ends = append(ends, *pt)
+				}
+			}
+		}
+		if !reflect.DeepEqual(targets, v.targets) {
+			t.Errorf("test=%d: close targets %v, want %v", i, targets, v.targets)
+		}
+		if !reflect.DeepEqual(ends, v.ends) {
+			t.Errorf("test=%d: points after close %v, want %v", i, ends, v.ends)
+		}
+	}
+}
+
+// polylineDistance returns the distance of p from the polyline
+// through pts.
+func polylineDistance(p Tuple, pts []Tuple) float64 {
+	d := math.Inf(1)
+	for i := 1; i < len(pts); i++ {
+		d = math.Min(d, segmentDistance(p, pts[i-1], pts[i]))
+	}
+	return d
+}
+
+// cubicPoint evaluates a cubic Bézier curve at parameter t.
+func cubicPoint(p0, p1, p2, p3 Tuple, t float64) Tuple {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return Tuple{
+		a*p0[0] + b*p1[0] + c*p2[0] + d*p3[0],
+		a*p0[1] + b*p1[1] + c*p2[1] + d*p3[1],
+	}
+}
+
+func TestFlattenCubic(t *testing.T) {
+	curves := [][4]Tuple{
+		{{10, 0}, {10, kappa * 10}, {kappa * 10, 10}, {0, 10}},
+		{{0, 0}, {100, 200}, {-100, 200}, {0, 0}},
+		{{0, 0}, {1, 0}, {2, 0}, {3, 0}},
+		{{0, 0}, {50, -40}, {-20, 90}, {300, 5}},
+	}
+	for i, c := range curves {
+		prev := 0
+		for _, tol := range []float64{10, 1, 0.1, 0.001} {
+			pts := FlattenCubic(c[0], c[1], c[2], c[3], tol)
+			if len(pts) == 0 || pts[len(pts)-1] != c[3] {
+				t.Errorf("test=%d tol=%g: flattening does not end at %v: %v", i, tol, c[3], pts)
+				continue
+			}
+			if len(pts) < prev {
+				t.Errorf("test=%d tol=%g: %d points is fewer than %d for a looser tolerance", i, tol, len(pts), prev)
+			}
+			prev = len(pts)
+			line := append([]Tuple{c[0]}, pts...)
+			for s := 0; s <= 1000; s++ {
+				p := cubicPoint(c[0], c[1], c[2], c[3], float64(s)/1000)
+				if d := polylineDistance(p, line); d > tol {
+					t.Errorf("test=%d tol=%g: curve point %v is %g from the lines", i, tol, p, d)
+					break
+				}
+			}
+		}
+	}
+	if pts := FlattenCubic(Tuple{0, 0}, Tuple{1, 1}, Tuple{2, 1}, Tuple{3, 0}, 0); pts != nil {
+		t.Errorf("zero tolerance flattened to %v", pts)
+	}
+}
+
+func TestFlattenCircle(t *testing.T) {
+	for _, tol := range []float64{20, 1, 0.01} {
+		c, r := Tuple{3, -4}, 10.0
+		pts := FlattenCircle(c, r, tol)
+		if len(pts) < 5 || pts[0] != pts[len(pts)-1] || !closeTuple(&pts[0], &Tuple{13, -4}) {
+			t.Errorf("tol=%g: bad polygon %v", tol, pts)
+			continue
+		}
+		for s := 0; s < 1000; s++ {
+			a := 2 * math.Pi * float64(s) / 1000
+			p := Tuple{c[0] + r*math.Cos(a), c[1] + r*math.Sin(a)}
+			if d := polylineDistance(p, pts); d > tol+1e-9 {
+				t.Errorf("tol=%g: circle point %v is %g from the polygon", tol, p, d)
+				break
+			}
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	const tol = 0.05
+	svg, err := ParseSvg(`<svg><path d="M0 0 A10 10 0 0 1 20 0 Q30 10 40 0 z"/><circle cx="5" cy="5" r="3"/><rect width="4" height="4" rx="1"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	counts := make(map[InstructionType]int)
+	for di := range Flatten(svg.ParseDrawingInstructions(), tol) {
+		counts[di.Kind]++
+		switch di.Kind {
+		case MoveInstruction, LineInstruction, CloseInstruction, PaintInstruction:
+		default:
+			t.Errorf("unexpected %v instruction: %v", di.Kind, di.Error)
+		}
+	}
+	if counts[MoveInstruction] != 3 || counts[CloseInstruction] != 3 || counts[PaintInstruction] != 3 || counts[LineInstruction] < 20 {
+		t.Errorf("unexpected instruction counts: %v", counts)
+	}
+
+	dis := Flatten(svg.ParseDrawingInstructions(), 0)
+	if di := <-dis; di == nil || di.Kind != ErrorInstruction {
+		t.Errorf("zero tolerance gave %v, want an error", di)
+	}
+	if di, ok := <-dis; ok {
+		t.Errorf("unexpected instruction after error: %v", di)
+	}
+}
+
+func TestEncodePathData(t *testing.T) {
+	svg, err := ParseSvg(`<svg>
+<g transform="translate(10,0)"><path d="M0 0 L1 -1 L2.25 0 C3 1 4 1 5 0 z" fill="red"/></g>
+<circle cx="1.25" cy="2" r="1.5"/>
+<line x1="0" y1="0" x2="0.333333" y2="-0.0001" stroke="blue"/>
+</svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	vs := []struct {
+		precision int
+		ds        []string
+	}{
+		{-1, []string{"M10 0 11-1 12.25 0C13 1 14 1 15 0Z", "M2.75 2A1.5 1.5 0 0 1-0.25 2 1.5 1.5 0 0 1 2.75 2Z", "M0 0 0.333333-0.0001"}},
+		{2, []string{"M10 0 11-1 12.25 0C13 1 14 1 15 0Z", "M2.75 2A1.5 1.5 0 0 1-0.25 2 1.5 1.5 0 0 1 2.75 2Z", "M0 0 0.33 0"}},
+		{0, []string{"M10 0 11-1 12 0C13 1 14 1 15 0Z", "M3 2A2 2 0 0 1 0 2 2 2 0 0 1 3 2Z", "M0 0 0 0"}},
+	}
+	for _, v := range vs {
+		pds, err := EncodePathData(svg.ParseDrawingInstructions(), v.precision)
+		if err != nil {
+			t.Errorf("precision=%d: EncodePathData failed: %v", v.precision, err)
+			continue
+		}
+		var ds []string
+		for _, pd := range pds {
+			ds = append(ds, pd.D)
+			if pd.Paint == nil || pd.Paint.Kind != PaintInstruction {
+				t.Errorf("precision=%d: %q has paint %v", v.precision, pd.D, pd.Paint)
+			}
+		}
+		if !reflect.DeepEqual(ds, v.ds) {
+			t.Errorf("precision=%d: got %q, want %q", v.precision, ds, v.ds)
+		}
+	}
+
+	pds, err := EncodePathData(svg.ParseDrawingInstructions(), -1)
+	if err != nil {
+		t.Fatalf("EncodePathData failed: %v", err)
+	}
+	var src strings.Builder
+	src.WriteString("<svg>")
+	for _, pd := range pds {
+		fmt.Fprintf(&src, "<path d=%q/>", pd.D)
+	}
+	src.WriteString("</svg>")
+	again, err := ParseSvg(src.String(), "again", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg of %q failed: %v", src.String(), err)
+	}
+	want, _ := svg.ParseSegments(0.001)
+	got, err := again.ParseSegments(0.001)
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("re-parsed segments are %v (err=%v), want %v", got, err, want)
+	}
+	for i := range want {
+		if got[i].Closed != want[i].Closed {
+			t.Errorf("segment %d closed=%v, want %v", i, got[i].Closed, want[i].Closed)
+		}
+		var line []Tuple
+		for _, pt := range append(want[i].Points, want[i].Points[0]) {
+			line = append(line, Tuple(pt))
+		}
+		for _, pt := range got[i].Points {
+			if d := polylineDistance(Tuple(pt), line); d > 0.002 {
+				t.Errorf("segment %d point %v is %g from the original", i, pt, d)
+				break
+			}
+		}
+	}
+
+	bad, err := ParseSvg(`<svg><path d="M0,0 L1"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	if pds, err := EncodePathData(bad.ParseDrawingInstructions(), 3); err == nil {
+		t.Errorf("bad path data encoded as %v", pds)
+	}
+}
+
+// winding returns the nonzero winding number of the closed polygons
+// about p.
+func winding(p Tuple, polys []Segment) int {
+	w := 0
+	for _, s := range polys {
+		for i, a := range s.Points {
+			b := s.Points[(i+1)%len(s.Points)]
+			cross := (b[0]-a[0])*(p[1]-a[1]) - (p[0]-a[0])*(b[1]-a[1])
+			if a[1] <= p[1] && b[1] > p[1] && cross > 0 {
+				w++
+			} else if a[1] > p[1] && b[1] <= p[1] && cross < 0 {
+				w--
+			}
+		}
+	}
+	return w
+}
+
+func TestOutline(t *testing.T) {
+	const tol = 0.01
+	stroke := func(closed bool, cap, join string, pts ...[2]float64) Segment {
+		return Segment{Width: 2, Closed: closed, Points: pts, Stroke: "red", LineCap: cap, LineJoin: join, MiterLimit: 4}
+	}
+	vs := []struct {
+		name     string
+		s        Segment
+		in, out  []Tuple
+		polygons int
+	}{
+		{
+			name:     "butt",
+			s:        stroke(false, "butt", "miter", [2]float64{0, 0}, [2]float64{10, 0}),
+			in:       []Tuple{{0.1, 0.9}, {9.9, -0.9}, {5, 0}},
+			out:      []Tuple{{-0.1, 0}, {10.1, 0}, {5, 1.1}, {5, -1.1}},
+			polygons: 1,
+		},
+		{
+			name:     "square",
+			s:        stroke(false, "square", "miter", [2]float64{0, 0}, [2]float64{10, 0}),
+			in:       []Tuple{{-0.9, 0.9}, {10.9, -0.9}},
+			out:      []Tuple{{-1.1, 0}, {11.1, 0}},
+			polygons: 1,
+		},
+		{
+			name:     "miter",
+			s:        stroke(false, "butt", "miter", [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 10}),
+			in:       []Tuple{{10.9, -0.9}, {9.5, 0.5}, {10, 9.9}},
+			out:      []Tuple{{11.1, -1.1}, {5, 5}},
+			polygons: 1,
+		},
+		{
+			name:     "bevel",
+			s:        stroke(false, "butt", "bevel", [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 10}),
+			in:       []Tuple{{10.4, -0.4}},
+			out:      []Tuple{{10.9, -0.9}},
+			polygons: 1,
+		},
+		{
+			name:     "miter limit",
+			s:        stroke(false, "butt", "miter", [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{0, 1}),
+			out:      []Tuple{{12, 0}},
+			polygons: 1,
+		},
+		{
+			name:     "ring",
+			s:        stroke(true, "butt", "miter", [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 10}, [2]float64{0, 10}),
+			in:       []Tuple{{-0.9, -0.9}, {0.9, 5}, {10.9, 10.9}},
+			out:      []Tuple{{5, 5}, {1.1, 5}, {-1.1, 5}},
+			polygons: 2,
+		},
+		{
+			name:     "repeated points",
+			s:        stroke(false, "butt", "round", [2]float64{0, 0}, [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 0}, [2]float64{10, 10}),
+			in:       []Tuple{{0.1, 0.9}, {10.5, 0.5}, {9.5, 9.9}},
+			out:      []Tuple{{-0.1, 0}, {11.1, -1.1}, {5, 5}},
+			polygons: 1,
+		},
+		{
+			name:     "ring with closing point",
+			s:        stroke(true, "butt", "miter", [2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 10}, [2]float64{0, 10}, [2]float64{0, 0}),
+			in:       []Tuple{{-0.9, -0.9}, {0.9, 5}, {10.9, 10.9}},
+			out:      []Tuple{{5, 5}, {1.1, 5}, {-1.1, 5}},
+			polygons: 2,
+		},
+		{
+			name:     "zero length round",
+			s:        stroke(false, "round", "round", [2]float64{1, 1}, [2]float64{1, 1}),
+			in:       []Tuple{{1, 1}, {1.6, 1.6}, {0.1, 1}},
+			out:      []Tuple{{2.1, 1}, {1.8, 1.8}},
+			polygons: 1,
+		},
+		{
+			name:     "zero length square",
+			s:        stroke(true, "square", "miter", [2]float64{1, 1}),
+			in:       []Tuple{{1, 1}, {1.9, 1.9}, {0.1, 0.1}},
+			out:      []Tuple{{2.1, 1}, {1, -0.1}},
+			polygons: 1,
+		},
+		{
+			name: "zero length butt",
+			s:    stroke(false, "butt", "round", [2]float64{1, 1}, [2]float64{1, 1}),
+		},
+	}
+	for _, v := range vs {
+		polys := v.s.Outline(tol)
+		if len(polys) != v.polygons {
+			t.Errorf("%s: got %d polygons, want %d: %v", v.name, len(polys), v.polygons, polys)
+			continue
+		}
+		for _, p := range polys {
+			if !p.Closed || p.Fill != "red" || p.FillRule != "nonzero" || p.Stroke != "none" {
+				t.Errorf("%s: bad outline %+v", v.name, p)
+			}
+			for _, pt := range p.Points {
+				if math.IsNaN(pt[0]) || math.IsNaN(pt[1]) {
+					t.Errorf("%s: outline has point %v", v.name, pt)
+				}
+			}
+		}
+		for _, p := range v.in {
+			if winding(p, polys) == 0 {
+				t.Errorf("%s: %v is not inside %v", v.name, p, polys)
+			}
+		}
+		for _, p := range v.out {
+			if winding(p, polys) != 0 {
+				t.Errorf("%s: %v is inside %v", v.name, p, polys)
+			}
+		}
+	}
+
+	// With round caps and joins, the stroke covers exactly the
+	// points within half the width of the line.
+	pts := [][2]float64{{0, 0}, {10, 0}, {10, 10}, {3, 2}, {12, 1}, {4, 4}}
+	for _, closed := range []bool{false, true} {
+		s := stroke(closed, "round", "round", pts...)
+		polys := s.Outline(tol)
+		var line []Tuple
+		for _, p := range pts {
+			line = append(line, Tuple(p))
+		}
+		if closed {
+			line = append(line, line[0])
+		}
+		for x := -2.0; x <= 14; x += 0.1 {
+			for y := -2.0; y <= 12; y += 0.1 {
+				p := Tuple{x, y}
+				d := polylineDistance(p, line)
+				if in := winding(p, polys) != 0; d < 1-tol && !in || d > 1 && in {
+					t.Errorf("closed=%v: %v at distance %g is inside=%v", closed, p, d, in)
+				}
+			}
+		}
+	}
+
+	svg, err := ParseSvg(`<svg><path d="M1 1 L1 1 M5 5" stroke="red" stroke-width="2" stroke-linecap="round"/></svg>`, "test", 0)
+	if err != nil {
+		t.Fatalf("ParseSvg failed: %v", err)
+	}
+	segs, err := svg.ParseSegments(tol)
+	if err != nil || len(segs) != 1 {
+		t.Fatalf("zero length subpath gave segments %v (err=%v), want one", segs, err)
+	}
+	if polys := StrokeOutlines(segs, tol); len(polys) != 1 || winding(Tuple{1, 1}, polys) == 0 || winding(Tuple{2.1, 1}, polys) != 0 {
+		t.Errorf("zero length subpath has outlines %v", polys)
+	}
+	if polys := stroke(false, "round", "round", [2]float64{0, 0}, [2]float64{1, 0}).Outline(0); polys != nil {
+		t.Errorf("zero tolerance gave %v", polys)
+	}
+	unstroked := stroke(false, "butt", "miter", [2]float64{0, 0}, [2]float64{1, 0})
+	unstroked.Stroke = "none"
+	if polys := StrokeOutlines([]Segment{unstroked, stroke(false, "butt", "miter", [2]float64{0, 0}, [2]float64{1, 0})}, tol); len(polys) != 1 {
+		t.Errorf("got outlines %v, want one", polys)
+	}
+}
+
+func TestLexArcFlags(t *testing.T) {
+	vs := []struct {
+		path  bool
+		types []gl.ItemType
+		vals  []string
+	}{
+		{
+			types: []gl.ItemType{gl.ItemLetter, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber},
+			vals:  []string{"a", "5", " ", "5", " ", "0", " ", "1110", " ", "0"},
+		},
+		{
+			path:  true,
+			types: []gl.ItemType{gl.ItemLetter, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber, gl.ItemWSP, gl.ItemFlag, gl.ItemFlag, gl.ItemNumber, gl.ItemWSP, gl.ItemNumber},
+			vals:  []string{"a", "5", " ", "5", " ", "0", " ", "1", "1", "10", " ", "0"},
+		},
+	}
+	for i, v := range vs {
+		lex := gl.Lex
+		if v.path {
+			lex = gl.LexPath
+		}
+		l, _ := lex("x", "a5 5 0 1110 0")
+		for j, typ := range v.types {
+			if it := l.NextItem(); it.Type != typ || it.Value != v.vals[j] {
+				t.Errorf("test=%d item=%d: got %v, want type=%d %q", i, j, it, typ, v.vals[j])
+			}
+		}
+		if it := l.NextItem(); it.Type != gl.ItemEOS {
+			t.Errorf("test=%d: got %v, want EOS", i, it)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package svger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
+)
+
+// PathData holds the SVG path data, D, of the shapes drawn by the
+// instructions that preceded a PaintInstruction, Paint.
+type PathData struct {
+	D     string
+	Paint *DrawingInstruction
+}
+
+// EncodePathData reads drawing instructions from in until it is
+// closed, and returns the path data of each block of instructions
+// ended by a PaintInstruction. Moves, lines, curves and closes are
+// written as M, L, C and Z commands, and each circle as a closed pair
+// of arcs. Shapes drawn after the last PaintInstruction have a nil
+// Paint.
+//
+// Coordinates are written with at most precision digits after the
+// decimal point, or, if precision is negative, with as many as are
+// needed to represent them exactly. Repeated commands and
+// unnecessary separators are omitted to keep the data compact.
+//
+// Combined with Flatten, or simply with the transforms that
+// ParseDrawingInstructions applies, this writes the world space
+// geometry of an image.
+func EncodePathData(in <-chan *DrawingInstruction, precision int) ([]PathData, error) {
+	var pds []PathData
+	var err error
+	w := &pathWriter{precision: precision}
+	for di := range in {
+		if err != nil {
+			// Drain the remaining instructions.
+			continue
+		}
+		switch di.Kind {
+		case ErrorInstruction:
+			err = di.Error
+			if err == nil {
+				err = fmt.Errorf("drawing instruction error")
+			}
+		case PaintInstruction:
+			if d := w.String(); d != "" {
+				pds = append(pds, PathData{D: d, Paint: di})
+			}
+			w.reset()
+		default:
+			w.instruction(di)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d := w.String(); d != "" {
+		pds = append(pds, PathData{D: d})
+	}
+	return pds, nil
+}
+
+// pathWriter accumulates compact path data.
+type pathWriter struct {
+	b         strings.Builder
+	precision int
+	// xf, if not nil, is applied to all of the coordinates.
+	xf *mt.Transform
+	// cmd is the last command written.
+	cmd byte
+	// number is true if the last thing written was a number.
+	number bool
+}
+
+func (w *pathWriter) String() string {
+	return w.b.String()
+}
+
+func (w *pathWriter) reset() {
+	w.b.Reset()
+	w.cmd = 0
+	w.number = false
+}
+
+// command starts a command, omitting its letter if it would repeat
+// the previous command, or is a line following a move.
+func (w *pathWriter) command(cmd byte) {
+	if cmd == w.cmd && cmd != 'M' && cmd != 'Z' || cmd == 'L' && w.cmd == 'M' {
+		return
+	}
+	w.b.WriteByte(cmd)
+	w.cmd = cmd
+	w.number = false
+}
+
+// num writes a number, separated from a preceding number only if
+// necessary.
+func (w *pathWriter) num(f float64) {
+	var s string
+	if w.precision < 0 {
+		s = formatNumber(f)
+	} else {
+		s = strconv.FormatFloat(f, 'f', w.precision, 64)
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		if s == "-0" {
+			s = "0"
+		}
+	}
+	if w.number && s[0] != '-' {
+		w.b.WriteByte(' ')
+	}
+	w.b.WriteString(s)
+	w.number = true
+}
+
+// point writes the coordinates of a point.
+func (w *pathWriter) point(t Tuple) {
+	x, y := t[0], t[1]
+	if w.xf != nil {
+		x, y = w.xf.Apply(x, y)
+	}
+	w.num(x)
+	w.num(y)
+}
+
+// flag writes an arc flag, which is always separated from a preceding
+// number.
+func (w *pathWriter) flag(f bool) {
+	if w.number {
+		w.b.WriteByte(' ')
+	}
+	if f {
+		w.b.WriteByte('1')
+	} else {
+		w.b.WriteByte('0')
+	}
+	w.number = true
+}
+
+// instruction writes the path data of a drawing instruction.
+func (w *pathWriter) instruction(di *DrawingInstruction) {
+	switch di.Kind {
+	case MoveInstruction:
+		w.command('M')
+		w.point(*di.M)
+	case LineInstruction:
+		w.command('L')
+		w.point(*di.M)
+	case CloseInstruction:
+		w.command('Z')
+	case CurveInstruction:
+		cp := di.CurvePoints
+		w.command('C')
+		w.point(*cp.C1)
+		w.point(*cp.C2)
+		w.point(*cp.T)
+	case CircleInstruction:
+		w.circle(*di.M, *di.Radius)
+	}
+}
+
+// circle writes a circle as two arcs, starting and ending at its
+// point of greatest x. If xf distorts the circle, it is instead
+// written as cubic curves.
+func (w *pathWriter) circle(c Tuple, r float64) {
+	if w.xf != nil && !isSimilarity(w.xf) {
+		w.command('M')
+		w.point(Tuple{c[0] + r, c[1]})
+		for _, cp := range ellipseCubics(c[0], c[1], r, r) {
+			w.command('C')
+			w.point(cp[0])
+			w.point(cp[1])
+			w.point(cp[2])
+		}
+		w.command('Z')
+		return
+	}
+	sweep := true
+	scaled := r
+	if w.xf != nil {
+		// A reflection reverses the direction of the arcs.
+		sweep = w.xf.Determinant() > 0
+		scaled = r * transformScale(w.xf)
+	}
+	w.command('M')
+	w.point(Tuple{c[0] + r, c[1]})
+	for _, end := range []Tuple{{c[0] - r, c[1]}, {c[0] + r, c[1]}} {
+		w.command('A')
+		w.num(scaled)
+		w.num(scaled)
+		w.num(0)
+		w.flag(false)
+		w.flag(sweep)
+		w.point(end)
+	}
+	w.command('Z')
+}
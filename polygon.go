@@ -1,16 +1,101 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+	"fmt"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // Polygon is a closed shape of straight line segments
 type Polygon struct {
-	ID        string `xml:"id,attr"`
-	Transform string `xml:"transform,attr"`
-	Style     string `xml:"style,attr"`
-	Points    string `xml:"points,attr"`
+	ID             string  `xml:"id,attr"`
+	Class          string  `xml:"class,attr"`
+	Transform      string  `xml:"transform,attr"`
+	Style          string  `xml:"style,attr"`
+	Points         string  `xml:"points,attr"`
+	Fill           string  `xml:"fill,attr"`
+	Stroke         string  `xml:"stroke,attr"`
+	StrokeWidth    float64 `xml:"-"`
+	StrokeLineCap  string  `xml:"stroke-linecap,attr"`
+	StrokeLineJoin string  `xml:"stroke-linejoin,attr"`
+
+	group *Group
+	attrs []xml.Attr
+}
+
+// ParseDrawingInstructions implements the DrawingInstructionParser
+// interface
+func (p *Polygon) ParseDrawingInstructions() chan *DrawingInstruction {
+	if p.group == nil {
+		p.group = new(Group)
+		temp := mt.Identity()
+		p.group.Transform = &temp
+	}
+	cs := p.group.elementStyle("polygon", p.attrs)
+	polyTransform, terr := parseTransform(p.Transform)
+	transform := mt.MultiplyTransforms(*p.group.Transform, polyTransform)
+
+	draw := make(chan *DrawingInstruction)
+	go func() {
+		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
+
+		if !drawPoints(draw, p.Points, transform, true) {
+			return
+		}
+
+		draw <- cs.paintInstruction(p.group.strokeScale(&transform))
+	}()
+	return draw
+}
+
+// drawPoints emits the Move and Line instructions for a points
+// attribute value, and a Close instruction if closed is true. On a
+// parsing error an ErrorInstruction is emitted and false returned.
+func drawPoints(draw chan<- *DrawingInstruction, points string, transform mt.Transform, closed bool) bool {
+	tuples, err := parsePoints(points)
+	if err == nil && len(tuples) == 0 {
+		err = fmt.Errorf("no points found in %q", points)
+	}
+	if err != nil {
+		draw <- &DrawingInstruction{
+			Kind:  ErrorInstruction,
+			Error: fmt.Errorf("error parsing points: %v", err),
+		}
+		return false
+	}
+	for i, t := range tuples {
+		k := LineInstruction
+		if i == 0 {
+			k = MoveInstruction
+		}
+		x, y := transform.Apply(t[0], t[1])
+		draw <- &DrawingInstruction{
+			Kind: k,
+			M:    &Tuple{x, y},
+		}
+	}
+	if closed {
+		x, y := transform.Apply(tuples[0][0], tuples[0][1])
+		draw <- &DrawingInstruction{
+			Kind: CloseInstruction,
+			M:    &Tuple{x, y},
+		}
+	}
+	return true
+}
 
-	transform mtransform.Transform
-	group     *Group
+// updateStyle sets the exported style fields of the polygon to their
+// computed values.
+func (p *Polygon) updateStyle() {
+	cs := p.group.elementStyle("polygon", p.attrs)
+	p.Fill, p.Stroke, p.StrokeWidth = cs.Fill, cs.Stroke, cs.StrokeWidth
+	p.StrokeLineCap, p.StrokeLineJoin = cs.StrokeLineCap, cs.StrokeLineJoin
 }
@@ -1,17 +1,65 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // PolyLine is a set of connected line segments that typically form a
 // closed shape
 type PolyLine struct {
-	ID        string `xml:"id,attr"`
-	Transform string `xml:"transform,attr"`
-	Style     string `xml:"style,attr"`
-	Points    string `xml:"points,attr"`
+	ID             string  `xml:"id,attr"`
+	Class          string  `xml:"class,attr"`
+	Transform      string  `xml:"transform,attr"`
+	Style          string  `xml:"style,attr"`
+	Points         string  `xml:"points,attr"`
+	Fill           string  `xml:"fill,attr"`
+	Stroke         string  `xml:"stroke,attr"`
+	StrokeWidth    float64 `xml:"-"`
+	StrokeLineCap  string  `xml:"stroke-linecap,attr"`
+	StrokeLineJoin string  `xml:"stroke-linejoin,attr"`
+
+	group *Group
+	attrs []xml.Attr
+}
+
+// ParseDrawingInstructions implements the DrawingInstructionParser
+// interface. Unlike a Polygon, the shape is not explicitly closed.
+func (p *PolyLine) ParseDrawingInstructions() chan *DrawingInstruction {
+	if p.group == nil {
+		p.group = new(Group)
+		temp := mt.Identity()
+		p.group.Transform = &temp
+	}
+	cs := p.group.elementStyle("polyline", p.attrs)
+	polyTransform, terr := parseTransform(p.Transform)
+	transform := mt.MultiplyTransforms(*p.group.Transform, polyTransform)
+
+	draw := make(chan *DrawingInstruction)
+	go func() {
+		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
+
+		if !drawPoints(draw, p.Points, transform, false) {
+			return
+		}
+
+		draw <- cs.paintInstruction(p.group.strokeScale(&transform))
+	}()
+	return draw
+}
 
-	transform mtransform.Transform
-	group     *Group
+// updateStyle sets the exported style fields of the polyline to their
+// computed values.
+func (p *PolyLine) updateStyle() {
+	cs := p.group.elementStyle("polyline", p.attrs)
+	p.Fill, p.Stroke, p.StrokeWidth = cs.Fill, cs.Stroke, cs.StrokeWidth
+	p.StrokeLineCap, p.StrokeLineJoin = cs.StrokeLineCap, cs.StrokeLineJoin
 }
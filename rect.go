@@ -1,90 +1,167 @@
 package svger
 
 import (
-	"zappem.net/pub/graphics/svger/mtransform"
+	"encoding/xml"
+
 	mt "zappem.net/pub/graphics/svger/mtransform"
 )
 
 // Rect is an SVG XML rect element
 type Rect struct {
-	ID          string  `xml:"id,attr"`
-	Width       float64 `xml:"width,attr"`
-	Height      float64 `xml:"height,attr"`
-	Transform   string  `xml:"transform,attr"`
-	Style       string  `xml:"style,attr"`
-	X           float64 `xml:"x,attr"`
-	Y           float64 `xml:"y,attr"`
-	Fill        string  `xml:"fill,attr"`
-	Stroke      string  `xml:"stroke,attr"`
-	StrokeWidth float64 `xml:"stroke-width,attr"`
+	ID          string   `xml:"id,attr"`
+	Class       string   `xml:"class,attr"`
+	Width       float64  `xml:"-"`
+	Height      float64  `xml:"-"`
+	Transform   string   `xml:"transform,attr"`
+	Style       string   `xml:"style,attr"`
+	X           float64  `xml:"-"`
+	Y           float64  `xml:"-"`
+	Rx          *float64 `xml:"-"`
+	Ry          *float64 `xml:"-"`
+	Fill        string   `xml:"fill,attr"`
+	Stroke      string   `xml:"stroke,attr"`
+	StrokeWidth float64  `xml:"-"`
 
-	transform mtransform.Transform
+	transform mt.Transform
 	group     *Group
+	attrs     []xml.Attr
 }
 
 // ParseDrawingInstructions implements the DrawingInstructionParser
 // interface
 func (r *Rect) ParseDrawingInstructions() chan *DrawingInstruction {
-	scale := 1.0
 	if r.group == nil {
 		r.group = new(Group)
 		temp := mt.Identity()
 		r.group.Transform = &temp
-	} else {
-		if r.Fill == "" && r.group.Fill != "" {
-			r.Fill = r.group.Fill
-		}
-		if r.Stroke == "" && r.group.Stroke != "" {
-			r.Stroke = r.group.Stroke
-		}
-		if r.StrokeWidth == 0 && r.group.StrokeWidth != 0 {
-			r.StrokeWidth = r.group.StrokeWidth
-		}
-		if r.group.Owner != nil {
-			scale = r.group.Owner.scale
-		}
 	}
+	cs := r.group.elementStyle("rect", r.attrs)
 	pdp := newPathDParse()
-	rectTransform := mt.Identity()
-	if r.Transform != "" {
-		if rt, err := parseTransform(r.Transform); err == nil {
-			rectTransform = rt
-		}
-	}
+	rectTransform, terr := parseTransform(r.Transform)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, *r.group.Transform)
 	pdp.transform = mt.MultiplyTransforms(pdp.transform, rectTransform)
 
 	draw := make(chan *DrawingInstruction)
 	go func() {
 		defer close(draw)
+		if terr != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: terr,
+			}
+			return
+		}
 
-		for i, pt := range []struct{ x, y float64 }{
-			{r.X, r.Y},
-			{r.X + r.Width, r.Y},
-			{r.X + r.Width, r.Y + r.Height},
-			{r.X, r.Y + r.Height},
-		} {
-			k := LineInstruction
-			if i == 0 {
-				k = MoveInstruction
+		// A zero size disables rendering of the element.
+		if r.Width <= 0 || r.Height <= 0 {
+			return
+		}
+
+		rx, ry := r.radii()
+		start := Tuple{r.X + rx, r.Y}
+		if rx == 0 || ry == 0 {
+			start = Tuple{r.X, r.Y}
+			for i, pt := range []struct{ x, y float64 }{
+				{r.X, r.Y},
+				{r.X + r.Width, r.Y},
+				{r.X + r.Width, r.Y + r.Height},
+				{r.X, r.Y + r.Height},
+			} {
+				k := LineInstruction
+				if i == 0 {
+					k = MoveInstruction
+				}
+				x, y := pdp.transform.Apply(pt.x, pt.y)
+				draw <- &DrawingInstruction{
+					Kind: k,
+					M:    &Tuple{x, y},
+				}
 			}
-			x, y := pdp.transform.Apply(pt.x, pt.y)
+		} else {
+			x, y := pdp.transform.Apply(start[0], start[1])
 			draw <- &DrawingInstruction{
-				Kind: k,
+				Kind: MoveInstruction,
 				M:    &Tuple{x, y},
 			}
+			// Each side is followed by the corner arc that
+			// turns onto the next side.
+			x0, y0, x1, y1 := r.X, r.Y, r.X+r.Width, r.Y+r.Height
+			for _, side := range []struct{ from, to, corner Tuple }{
+				{Tuple{x0 + rx, y0}, Tuple{x1 - rx, y0}, Tuple{x1, y0 + ry}},
+				{Tuple{x1, y0 + ry}, Tuple{x1, y1 - ry}, Tuple{x1 - rx, y1}},
+				{Tuple{x1 - rx, y1}, Tuple{x0 + rx, y1}, Tuple{x0, y1 - ry}},
+				{Tuple{x0, y1 - ry}, Tuple{x0, y0 + ry}, Tuple{x0 + rx, y0}},
+			} {
+				if side.from != side.to {
+					x, y := pdp.transform.Apply(side.to[0], side.to[1])
+					draw <- &DrawingInstruction{
+						Kind: LineInstruction,
+						M:    &Tuple{x, y},
+					}
+				}
+				curves, _ := arcToCubics(side.to, rx, ry, 0, false, true, side.corner)
+				for _, c := range curves {
+					draw <- curveInstruction(&pdp.transform, c)
+				}
+			}
 		}
+		x, y := pdp.transform.Apply(start[0], start[1])
 		draw <- &DrawingInstruction{
 			Kind: CloseInstruction,
+			M:    &Tuple{x, y},
 		}
 
-		s := scale * r.StrokeWidth
-		draw <- &DrawingInstruction{
-			Kind:        PaintInstruction,
-			StrokeWidth: &s,
-			Stroke:      &r.Stroke,
-			Fill:        &r.Fill,
-		}
+		draw <- cs.paintInstruction(r.group.strokeScale(&pdp.transform))
 	}()
 	return draw
 }
+
+// setLengths resolves the geometry attributes of the rect within a
+// viewport of size vp.
+func (r *Rect) setLengths(vp Tuple) {
+	rx, ry := -1.0, -1.0
+	setLengths(r.attrs, vp, map[string]*float64{
+		"x":      &r.X,
+		"y":      &r.Y,
+		"width":  &r.Width,
+		"height": &r.Height,
+		"rx":     &rx,
+		"ry":     &ry,
+	})
+	if rx >= 0 {
+		r.Rx = &rx
+	}
+	if ry >= 0 {
+		r.Ry = &ry
+	}
+}
+
+// radii returns the corner radii of the rectangle. A missing (or
+// negative) radius takes the value of the other one, and each is
+// limited to half of the corresponding side length.
+func (r *Rect) radii() (rx, ry float64) {
+	hasRx := r.Rx != nil && *r.Rx >= 0
+	hasRy := r.Ry != nil && *r.Ry >= 0
+	switch {
+	case hasRx && hasRy:
+		rx, ry = *r.Rx, *r.Ry
+	case hasRx:
+		rx, ry = *r.Rx, *r.Rx
+	case hasRy:
+		rx, ry = *r.Ry, *r.Ry
+	}
+	if rx > r.Width/2 {
+		rx = r.Width / 2
+	}
+	if ry > r.Height/2 {
+		ry = r.Height / 2
+	}
+	return
+}
+
+// updateStyle sets the exported style fields of the rect to their
+// computed values.
+func (r *Rect) updateStyle() {
+	cs := r.group.elementStyle("rect", r.attrs)
+	r.Fill, r.Stroke, r.StrokeWidth = cs.Fill, cs.Stroke, cs.StrokeWidth
+}
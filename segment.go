@@ -0,0 +1,154 @@
+package svger
+
+import "fmt"
+
+// A Segment of a path that contains a list of connected points, its
+// stroke Width and if the segment forms a closed loop.  Points are
+// defined in world space after any matrix transformation is applied.
+// The first point of a Closed segment is not repeated at its end.
+type Segment struct {
+	Width  float64
+	Closed bool
+	Points [][2]float64
+	// Fill, FillRule and Stroke hold the computed paint of the
+	// element the segment was drawn by. Fill and Stroke are
+	// "none" if the element isn't filled or stroked.
+	Fill     string
+	FillRule string
+	Stroke   string
+	// LineCap, LineJoin and MiterLimit hold the computed
+	// stroke-linecap, stroke-linejoin and stroke-miterlimit of
+	// the element, which Outline uses.
+	LineCap    string
+	LineJoin   string
+	MiterLimit float64
+	// Element numbers the painted elements in drawing order, from
+	// zero, so the segments that one element drew can be told
+	// apart from those of the next.
+	Element int
+}
+
+func (s *Segment) addPoint(p [2]float64) {
+	if n := len(s.Points); n > 0 && s.Points[n-1] == p {
+		return
+	}
+	s.Points = append(s.Points, p)
+}
+
+// ParseSegments returns the polylines drawn by all of the elements of
+// the image, with curves flattened to within tolerance (see Flatten).
+func (s *Svg) ParseSegments(tolerance float64) ([]Segment, error) {
+	return parseSegments(s.ParseDrawingInstructions(), tolerance)
+}
+
+// ParseSegments returns the polylines drawn by all of the elements of
+// the group, with curves flattened to within tolerance (see Flatten).
+func (g *Group) ParseSegments(tolerance float64) ([]Segment, error) {
+	return parseSegments(g.ParseDrawingInstructions(), tolerance)
+}
+
+// ParseSegments returns the polylines of the subpaths of the path,
+// with curves flattened to within tolerance (see Flatten).
+func (p *Path) ParseSegments(tolerance float64) ([]Segment, error) {
+	return parseSegments(p.ParseDrawingInstructions(), tolerance)
+}
+
+// parseSegments collects the flattened drawing instructions of one or
+// more elements into segments. Each Move and each Line that follows a
+// Close starts a new segment. A Paint instruction supplies the paint
+// of all segments since the previous one. A segment of a single
+// point is kept if it was drawn by a Line or Close, since its stroke
+// may still paint caps, but a Move alone draws nothing.
+func parseSegments(dis <-chan *DrawingInstruction, tolerance float64) ([]Segment, error) {
+	var segs []Segment
+	var err error
+	var current *Segment
+	// drawn indicates a Line or Close has added to current.
+	drawn := false
+	// painted counts the segments that have had their paint set,
+	// and elements the Paint instructions seen.
+	painted, elements := 0, 0
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if n := len(current.Points); current.Closed && n > 1 && current.Points[0] == current.Points[n-1] {
+			current.Points = current.Points[:n-1]
+		}
+		if len(current.Points) > 1 || drawn {
+			segs = append(segs, *current)
+		}
+		current, drawn = nil, false
+	}
+	var last [2]float64
+	for di := range Flatten(dis, tolerance) {
+		if err != nil {
+			// Drain the remaining instructions.
+			continue
+		}
+		switch di.Kind {
+		case ErrorInstruction:
+			err = di.Error
+			if err == nil {
+				err = fmt.Errorf("drawing instruction error")
+			}
+		case MoveInstruction:
+			finish()
+			last = *di.M
+			current = &Segment{Points: [][2]float64{last}}
+		case LineInstruction:
+			if current == nil || current.Closed {
+				finish()
+				current = &Segment{Points: [][2]float64{last}}
+			}
+			last = *di.M
+			current.addPoint(last)
+			drawn = true
+		case CloseInstruction:
+			if current == nil {
+				continue
+			}
+			if di.M != nil {
+				last = *di.M
+				current.addPoint(last)
+			}
+			current.Closed, drawn = true, true
+		case PaintInstruction:
+			finish()
+			for i := painted; i < len(segs); i++ {
+				segs[i].Element = elements
+				if di.StrokeWidth != nil {
+					segs[i].Width = *di.StrokeWidth
+				}
+				if di.Fill != nil {
+					segs[i].Fill = *di.Fill
+				}
+				if di.FillRule != nil {
+					segs[i].FillRule = *di.FillRule
+				}
+				if di.Stroke != nil {
+					segs[i].Stroke = *di.Stroke
+				}
+				if di.StrokeLineCap != nil {
+					segs[i].LineCap = *di.StrokeLineCap
+				}
+				if di.StrokeLineJoin != nil {
+					segs[i].LineJoin = *di.StrokeLineJoin
+				}
+				if di.StrokeMiterLimit != nil {
+					segs[i].MiterLimit = *di.StrokeMiterLimit
+				}
+			}
+			painted = len(segs)
+			elements++
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	finish()
+	for i := painted; i < len(segs); i++ {
+		segs[i].Element = elements
+	}
+	return segs, nil
+}
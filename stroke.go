@@ -0,0 +1,215 @@
+package svger
+
+import "math"
+
+// Outline returns closed polygons that, filled with the nonzero fill
+// rule, cover the area painted by stroking the segment with its
+// Width, LineCap, LineJoin and MiterLimit. An open segment has one
+// polygon, running forwards along one side of the segment and back
+// along the other. A closed segment has two, one for each side. The
+// polygons self-intersect where the segment turns sharply, which the
+// nonzero fill rule accounts for.
+//
+// Round caps and joins are flattened to within tolerance of their
+// arcs. The returned segments have a Fill of the segment's Stroke,
+// and no stroke of their own. A segment that isn't stroked or has no
+// width has no outline. The outlines have the Element of the segment.
+//
+// A segment of a single point, a zero length subpath, is outlined by
+// its round or square cap alone: a circle or an axis aligned square
+// of side Width about the point. With butt caps it has no outline.
+func (s Segment) Outline(tolerance float64) []Segment {
+	hw := s.Width / 2
+	if !(hw > 0) || s.Stroke == "none" || !(tolerance > 0) {
+		return nil
+	}
+	// Repeated points have no direction to offset them along.
+	var fwd []Tuple
+	for _, p := range s.Points {
+		if n := len(fwd); n == 0 || fwd[n-1] != Tuple(p) {
+			fwd = append(fwd, Tuple(p))
+		}
+	}
+	if n := len(fwd); s.Closed && n > 1 && fwd[0] == fwd[n-1] {
+		fwd = fwd[:n-1]
+	}
+	if len(fwd) == 0 {
+		return nil
+	}
+	st := &stroker{hw: hw, join: s.LineJoin, limit: s.MiterLimit, tolerance: tolerance}
+	if st.limit < 1 {
+		st.limit = 4
+	}
+	rev := make([]Tuple, len(fwd))
+	for i, p := range fwd {
+		rev[len(fwd)-1-i] = p
+	}
+
+	var polys [][]Tuple
+	if len(fwd) == 1 {
+		c := fwd[0]
+		switch s.LineCap {
+		case "round":
+			poly := append([]Tuple{{c[0] + hw, c[1]}}, st.arc(c, 0, 2*math.Pi)...)
+			polys = [][]Tuple{poly}
+		case "square":
+			polys = [][]Tuple{{
+				{c[0] + hw, c[1] + hw},
+				{c[0] - hw, c[1] + hw},
+				{c[0] - hw, c[1] - hw},
+				{c[0] + hw, c[1] - hw},
+			}}
+		}
+	} else if s.Closed {
+		polys = [][]Tuple{st.side(fwd, true), st.side(rev, true)}
+	} else {
+		poly := st.side(fwd, false)
+		poly = st.cap(poly, fwd[len(fwd)-2], fwd[len(fwd)-1], s.LineCap)
+		poly = append(poly, st.side(rev, false)...)
+		poly = st.cap(poly, rev[len(rev)-2], rev[len(rev)-1], s.LineCap)
+		polys = [][]Tuple{poly}
+	}
+
+	var outlines []Segment
+	for _, poly := range polys {
+		o := Segment{Closed: true, Fill: s.Stroke, FillRule: "nonzero", Stroke: "none", Element: s.Element}
+		for _, p := range poly {
+			o.addPoint([2]float64(p))
+		}
+		if n := len(o.Points); n > 1 && o.Points[0] == o.Points[n-1] {
+			o.Points = o.Points[:n-1]
+		}
+		if len(o.Points) > 2 {
+			outlines = append(outlines, o)
+		}
+	}
+	return outlines
+}
+
+// StrokeOutlines returns the outlines (see Segment.Outline) of all of
+// the stroked segments of segs.
+func StrokeOutlines(segs []Segment, tolerance float64) []Segment {
+	var outlines []Segment
+	for _, s := range segs {
+		outlines = append(outlines, s.Outline(tolerance)...)
+	}
+	return outlines
+}
+
+// stroker holds the parameters of outlining a stroke.
+type stroker struct {
+	// hw is half of the stroke width.
+	hw        float64
+	join      string
+	limit     float64
+	tolerance float64
+}
+
+// normal returns the vector of length hw to the left of the direction
+// from a to b, and the unit direction.
+func (st *stroker) normal(a, b Tuple) (n, d Tuple) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	l := math.Hypot(dx, dy)
+	d = Tuple{dx / l, dy / l}
+	return Tuple{-d[1] * st.hw, d[0] * st.hw}, d
+}
+
+// side returns the points offset to the left of pts. If closed, the
+// offset wraps around to the start of pts, otherwise it runs from
+// the first to the last point of pts.
+func (st *stroker) side(pts []Tuple, closed bool) []Tuple {
+	var out []Tuple
+	n := len(pts)
+	if !closed {
+		nrm, _ := st.normal(pts[0], pts[1])
+		out = append(out, add(pts[0], nrm))
+		for i := 1; i < n-1; i++ {
+			out = st.vertex(out, pts[i-1], pts[i], pts[i+1])
+		}
+		nrm, _ = st.normal(pts[n-2], pts[n-1])
+		return append(out, add(pts[n-1], nrm))
+	}
+	for i := 0; i < n; i++ {
+		out = st.vertex(out, pts[(i+n-1)%n], pts[i], pts[(i+1)%n])
+	}
+	return out
+}
+
+// vertex appends the offset points at p, where the segment turns from
+// the direction of a to p to that of p to b.
+func (st *stroker) vertex(out []Tuple, a, p, b Tuple) []Tuple {
+	n0, d0 := st.normal(a, p)
+	n1, d1 := st.normal(p, b)
+	cross := d0[0]*d1[1] - d0[1]*d1[0]
+	dot := d0[0]*d1[0] + d0[1]*d1[1]
+	if cross > 0 || (cross == 0 && dot > 0) {
+		// The left side is on the inside of the turn, or the
+		// segment is straight. Passing through p keeps the
+		// stroke covered where the offsets overlap.
+		if n0 == n1 {
+			return append(out, add(p, n0))
+		}
+		return append(out, add(p, n0), p, add(p, n1))
+	}
+	out = append(out, add(p, n0))
+	switch st.join {
+	case "round":
+		a0 := math.Atan2(n0[1], n0[0])
+		turn := math.Atan2(cross, dot)
+		if cross == 0 {
+			// The segment reverses, so the join runs around
+			// its end.
+			turn = -math.Pi
+		}
+		out = append(out, st.arc(p, a0, turn)...)
+	case "bevel":
+	default:
+		// The ratio of the miter length to the stroke width is
+		// 1/cos(turn/2).
+		if c := math.Sqrt((1 + dot) / 2); c > 0 && 1/c <= st.limit {
+			k := 1 / (1 + dot)
+			out = append(out, Tuple{p[0] + (n0[0]+n1[0])*k, p[1] + (n0[1]+n1[1])*k})
+		}
+	}
+	return append(out, add(p, n1))
+}
+
+// cap appends the cap at the end, b, of a segment arriving from a,
+// starting from its left offset and ending at its right one.
+func (st *stroker) cap(out []Tuple, a, b Tuple, style string) []Tuple {
+	n, d := st.normal(a, b)
+	switch style {
+	case "round":
+		out = append(out, st.arc(b, math.Atan2(n[1], n[0]), -math.Pi)...)
+	case "square":
+		e := Tuple{d[0] * st.hw, d[1] * st.hw}
+		out = append(out, add(add(b, n), e), add(sub(b, n), e))
+	}
+	return out
+}
+
+// arc returns the points strictly between the ends of an arc of
+// radius hw around c, from angle a0 through the signed angle sweep.
+func (st *stroker) arc(c Tuple, a0, sweep float64) []Tuple {
+	step := math.Pi / 2
+	if st.tolerance < st.hw {
+		// A chord spanning angle a deviates from the arc by
+		// hw*(1-cos(a/2)).
+		step = math.Min(step, 2*math.Acos(1-st.tolerance/st.hw))
+	}
+	n := int(math.Ceil(math.Abs(sweep) / step))
+	var pts []Tuple
+	for i := 1; i < n; i++ {
+		s, k := math.Sincos(a0 + sweep*float64(i)/float64(n))
+		pts = append(pts, Tuple{c[0] + st.hw*k, c[1] + st.hw*s})
+	}
+	return pts
+}
+
+func add(a, b Tuple) Tuple {
+	return Tuple{a[0] + b[0], a[1] + b[1]}
+}
+
+func sub(a, b Tuple) Tuple {
+	return Tuple{a[0] - b[0], a[1] - b[1]}
+}
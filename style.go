@@ -2,6 +2,7 @@ package svger
 
 import (
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 )
@@ -22,19 +23,169 @@ func parseDecimal(val string) float64 {
 	return f
 }
 
-// splitStyle unpacks the style string from a path element into a key
-// value map.
-func splitStyle(style string) map[string]string {
-	var r map[string]string
-	r = make(map[string]string)
-	props := strings.Split(style, ";")
+// computedStyle holds the computed values of the properties that
+// determine how an element is painted. All of them are inherited by
+// the children of an element.
+type computedStyle struct {
+	Color            string
+	Fill             string
+	FillOpacity      float64
+	FillRule         string
+	Stroke           string
+	StrokeOpacity    float64
+	StrokeWidth      float64
+	StrokeLineCap    string
+	StrokeLineJoin   string
+	StrokeMiterLimit float64
+}
+
+// defaultStyle returns the initial values of the style properties,
+// which apply to the outermost svg element.
+func defaultStyle() computedStyle {
+	return computedStyle{
+		Color:            "black",
+		Fill:             "black",
+		FillOpacity:      1,
+		FillRule:         "nonzero",
+		Stroke:           "none",
+		StrokeOpacity:    1,
+		StrokeWidth:      1,
+		StrokeLineCap:    "butt",
+		StrokeLineJoin:   "miter",
+		StrokeMiterLimit: 4,
+	}
+}
+
+// cascade returns the computed style of the element described by n,
+// whose parent has the computed style cs. In increasing order of
+// precedence, property values are taken from: the parent, the
+// presentation attributes of the element, the matching rules of
+// sheet, the style attribute of the element and finally any
+// "!important" declarations. Properties with the value "inherit"
+// retain the parent's value.
+func (cs computedStyle) cascade(n *styleNode, sheet styleSheet) computedStyle {
+	// Percentage widths are relative to the normalized diagonal
+	// of the viewport.
+	ref := lengthRef("stroke-width", n.viewport)
+	var inline []cssDeclaration
+	for _, attr := range n.attrs {
+		if attr.Name.Space != "" {
+			continue
+		}
+		if attr.Name.Local == "style" {
+			inline = parseCSSDeclarations(attr.Value)
+			continue
+		}
+		if val := strings.TrimSpace(attr.Value); val != "inherit" {
+			cs.set(attr.Name.Local, val, ref)
+		}
+	}
+	rules := sheet.matching(n)
+	for _, important := range []bool{false, true} {
+		for _, r := range rules {
+			cs.declare(r.decls, important, ref)
+		}
+		cs.declare(inline, important, ref)
+	}
+	return cs
+}
+
+// declare applies the declarations with the given importance.
+// Percentage lengths are relative to ref.
+func (cs *computedStyle) declare(decls []cssDeclaration, important bool, ref float64) {
+	for _, d := range decls {
+		if d.important != important || d.val == "inherit" {
+			continue
+		}
+		if !cs.set(d.prop, d.val, ref) && Debug {
+			log.Printf("TODO ingest style attr %q = %q", d.prop, d.val)
+		}
+	}
+}
 
-	for _, keyval := range props {
-		kv := strings.Split(strings.TrimSpace(keyval), ":")
-		if len(kv) >= 2 {
-			r[kv[0]] = kv[1]
+// set assigns a value to a named property. It returns false if the
+// property is not one that is tracked. Invalid values are ignored,
+// as they are for CSS. Percentage lengths are relative to ref.
+func (cs *computedStyle) set(prop, val string, ref float64) bool {
+	valid := true
+	switch prop {
+	case "color":
+		cs.Color = val
+	case "fill":
+		cs.Fill = val
+	case "fill-opacity":
+		if v := parseDecimal(val); v >= 0 {
+			cs.FillOpacity = v
+		} else {
+			valid = false
+		}
+	case "fill-rule":
+		if valid = val == "nonzero" || val == "evenodd"; valid {
+			cs.FillRule = val
+		}
+	case "stroke":
+		cs.Stroke = val
+	case "stroke-opacity":
+		if v := parseDecimal(val); v >= 0 {
+			cs.StrokeOpacity = v
+		} else {
+			valid = false
+		}
+	case "stroke-width":
+		if v, err := ParseLength(val, ref); err == nil && v >= 0 {
+			cs.StrokeWidth = v
+		} else {
+			valid = false
+		}
+	case "stroke-linecap":
+		if valid = val == "butt" || val == "round" || val == "square"; valid {
+			cs.StrokeLineCap = val
+		}
+	case "stroke-linejoin":
+		if valid = val == "miter" || val == "round" || val == "bevel"; valid {
+			cs.StrokeLineJoin = val
+		}
+	case "stroke-miterlimit":
+		if v := parseDecimal(val); v >= 1 {
+			cs.StrokeMiterLimit = v
+		} else {
+			valid = false
 		}
+	default:
+		return false
 	}
+	if !valid && Debug {
+		log.Printf("ignoring invalid %s value %q", prop, val)
+	}
+	return true
+}
 
-	return r
+// paint returns the paint value for a fill or stroke, resolving the
+// "currentColor" keyword and suppressing fully transparent paint.
+func (cs computedStyle) paint(val string, opacity float64) string {
+	if opacity == 0 {
+		return "none"
+	}
+	if val == "currentColor" {
+		return cs.Color
+	}
+	return val
+}
+
+// paintInstruction returns the PaintInstruction that concludes the
+// drawing of an element with this style. The stroke width is scaled
+// by scale.
+func (cs computedStyle) paintInstruction(scale float64) *DrawingInstruction {
+	width := cs.StrokeWidth * scale
+	miter := cs.StrokeMiterLimit
+	return &DrawingInstruction{
+		Kind:             PaintInstruction,
+		StrokeWidth:      &width,
+		Fill:             refString(cs.paint(cs.Fill, cs.FillOpacity)),
+		FillRule:         refString(cs.FillRule),
+		Stroke:           refString(cs.paint(cs.Stroke, cs.StrokeOpacity)),
+		StrokeLineCap:    refString(cs.StrokeLineCap),
+		StrokeLineJoin:   refString(cs.StrokeLineJoin),
+		StrokeMiterLimit: &miter,
+	}
 }
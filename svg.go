@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"strconv"
 	"strings"
 
 	"zappem.net/pub/graphics/svger/mtransform"
@@ -24,34 +23,69 @@ type Svg struct {
 	// Title is the title string for the SVG image
 	Title string `xml:"title"`
 	// Groups lists the top level groups
-	Groups []Group `xml:"g"`
+	Groups []*Group `xml:"g"`
 	// Width is the width of the SVG image
 	Width string `xml:"width,attr"`
 	// Height is the height of the SVG image
 	Height string `xml:"height,attr"`
 	// ViewBox holds the unparsed view box description
 	ViewBox string `xml:"viewBox,attr"`
+	// PreserveAspectRatio holds the unparsed description of how
+	// the view box is fitted to the width and height.
+	PreserveAspectRatio string `xml:"preserveAspectRatio,attr"`
 	// Elements lists all of the top level elements in this SVG image
 	Elements []DrawingInstructionParser
 	// Name names the SVG - typically the filename
 	Name string
 	// Transform holds the base frame information for the image
 	// groups and elements of the image are relative to this
-	// base frame.
+	// base frame. If units is true, it includes the mapping of
+	// the viewBox onto the width and height of the image once
+	// decoded.
 	Transform *mtransform.Transform
 	// scale holds the scaling factor applied to descendant
 	// coordinates.
 	scale float64
+	// units is true if the viewBox of the image is mapped onto
+	// its width and height, so coordinates are in physical units.
+	// Stroke widths are then scaled by the transforms of the
+	// elements.
+	units bool
 	// instructions is a common channel for emitting the sequence
 	// of drawing instructions
 	instructions chan *DrawingInstruction
 	// errors is a common channel for emitting decoding errors
 	errors chan error
+	// attrs holds the XML attributes of the svg element.
+	attrs []xml.Attr
+	// root is the group used to parent the top level Elements.
+	root *Group
+	// sheet holds the rules of the style elements of the image.
+	sheet styleSheet
+	// ids indexes the elements of the image by their id attribute.
+	ids map[string]DrawingInstructionParser
+	// viewport holds the size of the viewport in user units.
+	viewport Tuple
+	// styles holds the text of the style elements of the image.
+	styles []string
+	// defs holds the top level defs and symbol elements.
+	defs []*Group
+	// order records the document order of the top level Elements
+	// and Groups.
+	order []childRef
+}
+
+// childRef refers to a top level element of an Svg by its index in
+// either the Groups or the Elements of the image.
+type childRef struct {
+	group bool
+	index int
 }
 
 // Group represents an SVG group (usually located in a 'g' XML element)
 type Group struct {
 	ID              string
+	Class           string
 	Stroke          string
 	StrokeLineCap   string
 	StrokeLineJoin  string
@@ -65,6 +99,43 @@ type Group struct {
 	Owner           *Svg
 	instructions    chan *DrawingInstruction
 	errors          chan error
+	// attrs holds the XML attributes of the group.
+	attrs []xml.Attr
+	// tag is the XML element name of the group, if not "g".
+	tag string
+	// viewport holds the size of the nearest enclosing viewport
+	// in the user units of the group.
+	viewport Tuple
+	// defs holds the defs and symbol elements within the group.
+	defs []*Group
+	// computed and snode cache the computed style of the group and
+	// its description for selector matching, once updateStyle has
+	// been called.
+	computed *computedStyle
+	snode    *styleNode
+}
+
+// newViewport returns the viewport established by the svg or symbol
+// element of the group, within a parent viewport of size ref.
+func (g *Group) newViewport(ref Tuple) viewport {
+	var x, y, width, height, viewBox, par string
+	for _, attr := range g.attrs {
+		switch attr.Name.Local {
+		case "x":
+			x = attr.Value
+		case "y":
+			y = attr.Value
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "viewBox":
+			viewBox = attr.Value
+		case "preserveAspectRatio":
+			par = attr.Value
+		}
+	}
+	return newViewport(x, y, width, height, viewBox, par, ref)
 }
 
 // ParseDrawingInstructions implements the DrawingInstructionParser interface
@@ -89,71 +160,36 @@ func (g *Group) ParseDrawingInstructions() chan *DrawingInstruction {
 
 // UnmarshalXML implements the encoding.xml.Unmarshaler interface
 func (g *Group) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	g.attrs = start.Attr
+	if g.Transform == nil {
+		g.Transform = mtransform.NewTransform()
+	}
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
 		case "id":
 			g.ID = attr.Value
-		case "stroke":
-			g.Stroke = attr.Value
-		case "stroke-width":
-			floatValue, err := strconv.ParseFloat(attr.Value, 64)
-			if err != nil {
-				return err
-			}
-			g.StrokeWidth = floatValue
-		case "fill":
-			g.Fill = attr.Value
-		case "fill-rule":
-			g.FillRule = attr.Value
+		case "class":
+			g.Class = attr.Value
 		case "transform":
 			g.TransformString = attr.Value
 			t, err := parseTransform(g.TransformString)
 			if err != nil {
-				fmt.Println(err)
+				return fmt.Errorf("invalid %s element transform: %v", start.Name.Local, err)
 			}
+			t = mtransform.MultiplyTransforms(*g.Transform, t)
 			g.Transform = &t
-		case "style":
-			// another way to get some of the above
-			suppressStroke := false
-			suppressFill := false
-			for a, val := range splitStyle(attr.Value) {
-				switch a {
-				case "fill":
-					g.Fill = val
-				case "fill-opacity":
-					if v := parseDecimal(val); v == 0 {
-						suppressFill = true
-					}
-				case "stroke":
-					g.Stroke = val
-				case "stroke-linecap":
-					g.StrokeLineCap = val
-				case "stroke-linejoin":
-					g.StrokeLineJoin = val
-				case "stroke-opacity":
-					if v := parseDecimal(val); v == 0 {
-						suppressStroke = true
-					}
-				case "stroke-width":
-					g.StrokeWidth = parseDecimal(val)
-				default:
-					if Debug {
-						log.Printf("TODO ingest style attr %q = %q", a, val)
-					}
-				}
-			}
-			if suppressFill {
-				g.Fill = "none"
-			}
-			if suppressStroke {
-				g.Stroke = "none"
-			}
-		default:
-			if Debug {
-				log.Printf("TODO unable to parse %#v", attr)
-			}
 		}
 	}
+	if start.Name.Local != "g" {
+		g.tag = start.Name.Local
+	}
+	if start.Name.Local == "svg" {
+		// A nested svg element establishes a new viewport.
+		vp := g.newViewport(g.viewport)
+		t := mtransform.MultiplyTransforms(*g.Transform, vp.transform())
+		g.Transform = &t
+		g.viewport = vp.size()
+	}
 
 	for {
 		token, err := decoder.Token()
@@ -166,36 +202,24 @@ func (g *Group) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error
 			var elementStruct DrawingInstructionParser
 
 			switch tok.Name.Local {
-			case "g":
+			case "style":
+				if err := g.Owner.decodeStyle(decoder, &tok); err != nil {
+					return err
+				}
+				continue
+			case "g", "defs", "symbol", "svg":
 				sub := &Group{
-					Parent:         g,
-					Owner:          g.Owner,
-					StrokeLineCap:  g.StrokeLineCap,
-					StrokeLineJoin: g.StrokeLineJoin,
-					StrokeWidth:    g.StrokeWidth,
-					Stroke:         g.Stroke,
-					Fill:           g.Fill,
+					Parent:   g,
+					Owner:    g.Owner,
+					viewport: g.viewport,
 				}
 				x := mtransform.MultiplyTransforms(*mtransform.NewTransform(), *g.Transform)
 				sub.Transform = &x
 				elementStruct = sub
-			case "rect":
-				rect := &Rect{group: g}
-				elementStruct = rect
-			case "circle":
-				circ := &Circle{group: g}
-				elementStruct = circ
-			case "path":
-				path := &Path{
-					group:          g,
-					StrokeWidth:    g.StrokeWidth,
-					StrokeLineCap:  &g.StrokeLineCap,
-					StrokeLineJoin: &g.StrokeLineJoin,
-					Stroke:         &g.Stroke,
-					Fill:           &g.Fill,
-				}
-				elementStruct = path
 			default:
+				elementStruct = newElement(tok, g)
+			}
+			if elementStruct == nil {
 				if Debug {
 					log.Printf("TODO support for %q elements", tok.Name.Local)
 				}
@@ -204,9 +228,14 @@ func (g *Group) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error
 			if err = decoder.DecodeElement(elementStruct, &tok); err != nil {
 				return fmt.Errorf("error decoding element of Group: %v", err)
 			}
+			g.Owner.register(tok, elementStruct)
+			if isDefinition(tok) {
+				g.defs = append(g.defs, elementStruct.(*Group))
+				continue
+			}
 			g.Elements = append(g.Elements, elementStruct)
 		case xml.EndElement:
-			if tok.Name.Local == "g" {
+			if tok.Name.Local == start.Name.Local {
 				return nil
 			}
 		}
@@ -219,10 +248,8 @@ func (g *Group) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error
 func (s *Svg) ParseDrawingInstructions() chan *DrawingInstruction {
 	s.instructions = make(chan *DrawingInstruction, 100)
 	go func() {
-		var elecount int
 		defer close(s.instructions)
-		for _, e := range s.Elements {
-			elecount++
+		for _, e := range s.children() {
 			instrs := e.ParseDrawingInstructions()
 			for is := range instrs {
 				s.instructions <- is
@@ -231,34 +258,64 @@ func (s *Svg) ParseDrawingInstructions() chan *DrawingInstruction {
 				}
 			}
 		}
-		for _, g := range s.Groups {
-			instrs := g.ParseDrawingInstructions()
-			for is := range instrs {
-				s.instructions <- is
-				if is.Error != nil {
-					return
-				}
-			}
-		}
 	}()
 	return s.instructions
 }
 
+// children returns the top level Elements and Groups of the image in
+// document order, followed by any added to them after parsing.
+func (s *Svg) children() []DrawingInstructionParser {
+	var children []DrawingInstructionParser
+	listed := make(map[childRef]bool)
+	for _, ref := range s.order {
+		if ref.group && ref.index < len(s.Groups) {
+			children = append(children, s.Groups[ref.index])
+		} else if !ref.group && ref.index < len(s.Elements) {
+			children = append(children, s.Elements[ref.index])
+		} else {
+			continue
+		}
+		listed[ref] = true
+	}
+	for i, e := range s.Elements {
+		if !listed[childRef{index: i}] {
+			children = append(children, e)
+		}
+	}
+	for i, g := range s.Groups {
+		if !listed[childRef{group: true, index: i}] {
+			children = append(children, g)
+		}
+	}
+	return children
+}
+
 // UnmarshalXML implements the encoding.xml.Unmarshaler interface
 func (s *Svg) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
-	for {
-		for _, attr := range start.Attr {
-			if attr.Name.Local == "viewBox" {
-				s.ViewBox = attr.Value
-			}
-			if attr.Name.Local == "width" {
-				s.Width = attr.Value
-			}
-			if attr.Name.Local == "height" {
-				s.Height = attr.Value
-			}
+	s.attrs = start.Attr
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "viewBox":
+			s.ViewBox = attr.Value
+		case "width":
+			s.Width = attr.Value
+		case "height":
+			s.Height = attr.Value
+		case "preserveAspectRatio":
+			s.PreserveAspectRatio = attr.Value
 		}
+	}
+	if s.Transform == nil {
+		s.Transform = mtransform.NewTransform()
+	}
+	vp := newViewport("", "", s.Width, s.Height, s.ViewBox, s.PreserveAspectRatio, Tuple{})
+	if s.units {
+		t := mtransform.MultiplyTransforms(*s.Transform, vp.transform())
+		s.Transform = &t
+	}
+	s.viewport = vp.size()
 
+	for {
 		token, err := decoder.Token()
 		if err != nil {
 			return err
@@ -269,71 +326,343 @@ func (s *Svg) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
 			var dip DrawingInstructionParser
 
 			switch tok.Name.Local {
-			case "g":
-				g := &Group{Owner: s, Transform: mtransform.NewTransform()}
+			case "style":
+				if err := s.decodeStyle(decoder, &tok); err != nil {
+					return err
+				}
+				continue
+			case "g", "svg":
+				g := s.newGroup()
 				if err = decoder.DecodeElement(g, &tok); err != nil {
 					return fmt.Errorf("error decoding group element within SVG struct: %s", err)
 				}
-				s.Groups = append(s.Groups, *g)
+				s.register(tok, g)
+				s.order = append(s.order, childRef{group: true, index: len(s.Groups)})
+				s.Groups = append(s.Groups, g)
 				continue
-			case "rect":
-				dip = &Rect{}
-			case "circle":
-				dip = &Circle{}
-			case "path":
-				dip = &Path{}
-
-			default:
+			case "defs", "symbol":
+				g := s.newGroup()
+				if err = decoder.DecodeElement(g, &tok); err != nil {
+					return fmt.Errorf("error decoding %s element within SVG struct: %s", tok.Name.Local, err)
+				}
+				s.register(tok, g)
+				s.defs = append(s.defs, g)
 				continue
+			default:
+				if dip = newElement(tok, s.rootGroup()); dip == nil {
+					continue
+				}
 			}
 
 			if err = decoder.DecodeElement(dip, &tok); err != nil {
 				return fmt.Errorf("error decoding element of SVG struct: %s", err)
 			}
+			s.register(tok, dip)
 
+			s.order = append(s.order, childRef{index: len(s.Elements)})
 			s.Elements = append(s.Elements, dip)
 
 		case xml.EndElement:
 			if tok.Name.Local == "svg" {
+				// Style elements may follow the groups they
+				// apply to.
+				s.updateStyles()
 				return nil
 			}
 		}
 	}
 }
 
-// ParseSvg parses an SVG string into an SVG struct
-func ParseSvg(str string, name string, scale float64) (*Svg, error) {
-	var svg Svg
-	svg.Name = name
-	svg.Transform = mtransform.NewTransform()
-	if scale > 0 {
-		svg.Transform.Scale(scale, scale)
-		svg.scale = scale
+// decodeStyle decodes a style element and adds its rules to the
+// stylesheet of the image.
+func (s *Svg) decodeStyle(decoder *xml.Decoder, start *xml.StartElement) error {
+	var style struct {
+		Text string `xml:",chardata"`
 	}
-	if scale < 0 {
-		svg.Transform.Scale(1.0/-scale, 1.0/-scale)
-		svg.scale = 1.0 / -scale
+	if err := decoder.DecodeElement(&style, start); err != nil {
+		return fmt.Errorf("error decoding style element: %v", err)
+	}
+	if s != nil {
+		s.sheet = s.sheet.parseCSS(style.Text)
+		s.styles = append(s.styles, style.Text)
 	}
+	return nil
+}
 
-	err := xml.Unmarshal([]byte(str), &svg)
-	if err != nil {
-		return nil, fmt.Errorf("ParseSvg Error: %v", err)
+// newElement returns an element, parented by g, to decode the
+// content of the XML element started by tok. Its length valued
+// attributes are resolved within the viewport of g. If the element
+// type is not supported, nil is returned.
+func newElement(tok xml.StartElement, g *Group) DrawingInstructionParser {
+	switch tok.Name.Local {
+	case "rect":
+		r := &Rect{group: g, attrs: tok.Attr}
+		r.setLengths(g.viewport)
+		return r
+	case "circle":
+		c := &Circle{group: g, attrs: tok.Attr}
+		c.setLengths(g.viewport)
+		return c
+	case "ellipse":
+		e := &Ellipse{group: g, attrs: tok.Attr}
+		e.setLengths(g.viewport)
+		return e
+	case "line":
+		l := &Line{group: g, attrs: tok.Attr}
+		l.setLengths(g.viewport)
+		return l
+	case "polygon":
+		return &Polygon{group: g, attrs: tok.Attr}
+	case "polyline":
+		return &PolyLine{group: g, attrs: tok.Attr}
+	case "path":
+		return &Path{group: g, attrs: tok.Attr}
+	case "use":
+		u := &Use{group: g, attrs: tok.Attr}
+		u.setLengths(g.viewport)
+		return u
 	}
+	return nil
+}
 
-	for i := range svg.Groups {
-		svg.Groups[i].SetOwner(&svg)
-		if svg.Groups[i].Transform == nil {
-			svg.Groups[i].Transform = mtransform.NewTransform()
+// isDefinition confirms that tok starts an element whose content is
+// only drawn when referenced by a use element.
+func isDefinition(tok xml.StartElement) bool {
+	return tok.Name.Local == "defs" || tok.Name.Local == "symbol"
+}
+
+// register adds an element to the ID index of the image, provided
+// its start tag, tok, has an id attribute. Where IDs are duplicated,
+// the first element wins.
+func (s *Svg) register(tok xml.StartElement, e DrawingInstructionParser) {
+	if s == nil {
+		return
+	}
+	for _, attr := range tok.Attr {
+		if attr.Name.Space != "" || attr.Name.Local != "id" || attr.Value == "" {
+			continue
+		}
+		if s.ids == nil {
+			s.ids = make(map[string]DrawingInstructionParser)
+		}
+		if _, dup := s.ids[attr.Value]; !dup {
+			s.ids[attr.Value] = e
 		}
 	}
-	return &svg, nil
+}
+
+// ElementByID returns the element of the image with the given id
+// attribute, or nil if there is no such element. Elements inside
+// defs and symbol elements are included.
+func (s *Svg) ElementByID(id string) DrawingInstructionParser {
+	return s.ids[id]
+}
+
+// rootGroup returns the group that parents the top level Elements of
+// the SVG image. It contributes no style or transform of its own.
+func (s *Svg) rootGroup() *Group {
+	if s.root == nil {
+		s.root = s.newGroup()
+	}
+	return s.root
+}
+
+// newGroup returns an empty top level group of the image, which is
+// positioned in the base frame of the image.
+func (s *Svg) newGroup() *Group {
+	t := mtransform.Identity()
+	if s.Transform != nil {
+		t = mtransform.MultiplyTransforms(t, *s.Transform)
+	}
+	return &Group{Owner: s, Transform: &t, viewport: s.viewport}
+}
+
+// node returns the description of the svg element used for
+// stylesheet selector matching.
+func (s *Svg) node() *styleNode {
+	return &styleNode{name: "svg", attrs: s.attrs, viewport: s.viewport}
+}
+
+// style returns the computed style of the svg element.
+func (s *Svg) style() computedStyle {
+	return defaultStyle().cascade(s.node(), s.sheet)
+}
+
+// isRoot confirms g is the root group of its owner.
+func (g *Group) isRoot() bool {
+	return g.Owner != nil && g.Owner.root == g
+}
+
+// node returns the description of the group used for stylesheet
+// selector matching.
+func (g *Group) node() *styleNode {
+	if g.snode != nil {
+		return g.snode
+	}
+	var parent *styleNode
+	switch {
+	case g.isRoot():
+		return g.Owner.node()
+	case g.Parent != nil:
+		parent = g.Parent.node()
+	case g.Owner != nil:
+		parent = g.Owner.node()
+	}
+	name := "g"
+	if g.tag != "" {
+		name = g.tag
+	}
+	return &styleNode{name: name, attrs: g.attrs, parent: parent, viewport: g.viewport}
+}
+
+// styleSheet returns the stylesheet that applies to the group.
+func (g *Group) styleSheet() styleSheet {
+	if g.Owner == nil {
+		return nil
+	}
+	return g.Owner.sheet
+}
+
+// style returns the computed style of the group, as inherited from
+// its ancestors.
+func (g *Group) style() computedStyle {
+	if g.computed != nil {
+		return *g.computed
+	}
+	switch {
+	case g.isRoot():
+		return g.Owner.style()
+	case g.Parent != nil:
+		return g.Parent.style().cascade(g.node(), g.styleSheet())
+	case g.Owner != nil:
+		return g.Owner.style().cascade(g.node(), g.styleSheet())
+	default:
+		return defaultStyle().cascade(g.node(), nil)
+	}
+}
+
+// strokeScale returns the factor by which the stroke widths of
+// elements of the group, drawn with transform t, are scaled. Unless
+// the units of the image are mapped, this is the scale it was parsed
+// with.
+func (g *Group) strokeScale(t *mtransform.Transform) float64 {
+	switch {
+	case g.Owner == nil:
+		return 1
+	case g.Owner.units:
+		return transformScale(t)
+	case g.Owner.scale == 0:
+		// The image was decoded without ParseSvg.
+		return 1
+	default:
+		return g.Owner.scale
+	}
+}
+
+// elementStyle returns the computed style of a child element of the
+// group, of type name and with XML attributes attrs.
+func (g *Group) elementStyle(name string, attrs []xml.Attr) computedStyle {
+	n := &styleNode{name: name, attrs: attrs, parent: g.node(), viewport: g.viewport}
+	return g.style().cascade(n, g.styleSheet())
+}
+
+// updateStyle sets the exported style fields of the group to their
+// computed values, and caches them for the elements of the group. The
+// styles of its ancestors are expected to be up to date.
+func (g *Group) updateStyle() {
+	g.computed, g.snode = nil, nil
+	cs := g.style()
+	g.snode = g.node()
+	g.computed = &cs
+	g.Stroke = cs.Stroke
+	g.StrokeLineCap = cs.StrokeLineCap
+	g.StrokeLineJoin = cs.StrokeLineJoin
+	g.StrokeWidth = cs.StrokeWidth
+	g.Fill = cs.Fill
+	g.FillRule = cs.FillRule
+}
+
+// updateStyles sets the exported style fields of all of the groups and
+// elements of the image, including those in defs and symbol elements.
+func (s *Svg) updateStyles() {
+	for _, g := range s.Groups {
+		g.updateStyles()
+	}
+	for _, g := range s.defs {
+		g.updateStyles()
+	}
+	for _, e := range s.Elements {
+		if e, ok := e.(styled); ok {
+			e.updateStyle()
+		}
+	}
+}
+
+// updateStyles sets the exported style fields of the group and all of
+// its descendant groups and elements.
+func (g *Group) updateStyles() {
+	g.updateStyle()
+	for _, e := range g.Elements {
+		switch e := e.(type) {
+		case *Group:
+			e.updateStyles()
+		case styled:
+			e.updateStyle()
+		}
+	}
+	for _, d := range g.defs {
+		d.updateStyles()
+	}
+}
+
+// styled is implemented by the elements with exported style fields.
+// Its updateStyle method sets them to their computed values.
+type styled interface {
+	updateStyle()
+}
+
+// ParseSvg parses an SVG string into an SVG struct. Coordinates and
+// stroke widths are those of the user space of the image, multiplied
+// by scale. A negative scale divides them by -scale instead. The
+// viewBox, if any, is not mapped onto the width and height of the
+// image; see ParseSvgInUnits for that.
+func ParseSvg(str string, name string, scale float64) (*Svg, error) {
+	return parseSvg(strings.NewReader(str), name, scale, false)
 }
 
 // ParseSvgFromReader parses an SVG struct from an io.Reader
 func ParseSvgFromReader(r io.Reader, name string, scale float64) (*Svg, error) {
+	return parseSvg(r, name, scale, false)
+}
+
+// ParseSvgInUnits parses an SVG string into an SVG struct whose
+// coordinates and stroke widths are expressed in unit, for example
+// Mm, however the image was authored. The viewBox is mapped onto the
+// width and height of the image, and stroke widths are scaled by the
+// transforms that apply to them.
+func ParseSvgInUnits(str string, name string, unit Unit) (*Svg, error) {
+	if unit <= 0 {
+		return nil, fmt.Errorf("ParseSvg Error: invalid unit %v", unit)
+	}
+	return parseSvg(strings.NewReader(str), name, 1/float64(unit), true)
+}
+
+// ParseSvgFromReaderInUnits parses an SVG struct from an io.Reader
+// with coordinates and stroke widths expressed in unit.
+func ParseSvgFromReaderInUnits(r io.Reader, name string, unit Unit) (*Svg, error) {
+	if unit <= 0 {
+		return nil, fmt.Errorf("ParseSvg Error: invalid unit %v", unit)
+	}
+	return parseSvg(r, name, 1/float64(unit), true)
+}
+
+// parseSvg parses an SVG struct from r, with the base frame scaled by
+// scale. If units is true, the root viewport is mapped as well.
+func parseSvg(r io.Reader, name string, scale float64, units bool) (*Svg, error) {
 	var svg Svg
 	svg.Name = name
 	svg.Transform = mtransform.NewTransform()
+	svg.scale = 1
 	if scale > 0 {
 		svg.Transform.Scale(scale, scale)
 		svg.scale = scale
@@ -342,6 +671,7 @@ func ParseSvgFromReader(r io.Reader, name string, scale float64) (*Svg, error) {
 		svg.Transform.Scale(1.0/-scale, 1.0/-scale)
 		svg.scale = 1.0 / -scale
 	}
+	svg.units = units
 
 	if err := xml.NewDecoder(r).Decode(&svg); err != nil {
 		return nil, fmt.Errorf("ParseSvg Error: %v", err)
@@ -357,30 +687,19 @@ func ParseSvgFromReader(r io.Reader, name string, scale float64) (*Svg, error) {
 }
 
 // ViewBoxValues returns all the numerical values in the viewBox
-// attribute.
+// attribute. The values may be separated by white space and/or
+// commas.
 func (s *Svg) ViewBoxValues() ([]float64, error) {
-	var vals []float64
-
 	if s.ViewBox == "" {
-		return vals, errors.New("viewBox attribute is empty")
+		return nil, errors.New("viewBox attribute is empty")
 	}
-
-	split := strings.Split(s.ViewBox, " ")
-
-	for _, val := range split {
-		ival, err := strconv.ParseFloat(val, 64)
-		if err != nil {
-			return vals, err
-		}
-		vals = append(vals, ival)
-	}
-
-	return vals, nil
+	return parseViewBox(s.ViewBox)
 }
 
 // SetOwner sets the owner of a SVG Group
 func (g *Group) SetOwner(svg *Svg) {
 	g.Owner = svg
+	g.updateStyle()
 	for _, gn := range g.Elements {
 		switch gn.(type) {
 		case *Group:
@@ -0,0 +1,199 @@
+package svger
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
+)
+
+// Use is an SVG use element, which draws a copy of another element
+// of the image.
+type Use struct {
+	ID        string  `xml:"id,attr"`
+	Class     string  `xml:"class,attr"`
+	Href      string  `xml:"href,attr"`
+	Transform string  `xml:"transform,attr"`
+	Style     string  `xml:"style,attr"`
+	X         float64 `xml:"-"`
+	Y         float64 `xml:"-"`
+
+	group *Group
+	attrs []xml.Attr
+	// chain holds the ids of the elements being instantiated by
+	// the enclosing use elements.
+	chain []string
+}
+
+// ParseDrawingInstructions implements the DrawingInstructionParser
+// interface. The referenced element is drawn as if it were the only
+// child of a group that has the style of the use element and is
+// transformed by the transform attribute of the use element followed
+// by a translation of (x,y).
+func (u *Use) ParseDrawingInstructions() chan *DrawingInstruction {
+	if u.group == nil {
+		u.group = new(Group)
+		temp := mt.Identity()
+		u.group.Transform = &temp
+	}
+
+	draw := make(chan *DrawingInstruction)
+	go func() {
+		defer close(draw)
+
+		target, err := u.instance()
+		if err != nil {
+			draw <- &DrawingInstruction{
+				Kind:  ErrorInstruction,
+				Error: err,
+			}
+			return
+		}
+		if target == nil {
+			return
+		}
+		for is := range target.ParseDrawingInstructions() {
+			draw <- is
+			if is.Error != nil {
+				return
+			}
+		}
+	}()
+	return draw
+}
+
+// setLengths resolves the position attributes of the use element
+// within a viewport of size vp.
+func (u *Use) setLengths(vp Tuple) {
+	setLengths(u.attrs, vp, map[string]*float64{
+		"x": &u.X,
+		"y": &u.Y,
+	})
+}
+
+// href returns the reference of the use element. The SVG 2 href
+// attribute is preferred over the older xlink:href one.
+func (u *Use) href() string {
+	href := ""
+	for _, attr := range u.attrs {
+		if attr.Name.Local != "href" {
+			continue
+		}
+		if attr.Name.Space == "" {
+			return attr.Value
+		}
+		href = attr.Value
+	}
+	if href == "" {
+		return u.Href
+	}
+	return href
+}
+
+// instance returns a copy of the referenced element, parented by a
+// group that stands in for the use element. If the reference can't
+// be resolved, nil is returned. A reference cycle is an error.
+func (u *Use) instance() (DrawingInstructionParser, error) {
+	href := u.href()
+	if !strings.HasPrefix(href, "#") {
+		if Debug {
+			log.Printf("TODO support for external reference %q", href)
+		}
+		return nil, nil
+	}
+	id := href[1:]
+	for _, prev := range u.chain {
+		if prev == id {
+			return nil, fmt.Errorf("use reference cycle: %s -> %s", strings.Join(u.chain, " -> "), id)
+		}
+	}
+	var target DrawingInstructionParser
+	if u.group.Owner != nil {
+		target = u.group.Owner.ElementByID(id)
+	}
+	if target == nil {
+		if Debug {
+			log.Printf("no element with id=%q for use", id)
+		}
+		return nil, nil
+	}
+
+	useTransform, err := parseTransform(u.Transform)
+	if err != nil {
+		return nil, err
+	}
+	t := mt.MultiplyTransforms(*u.group.Transform, useTransform)
+	t = mt.MultiplyTransforms(t, mt.Translate(u.X, u.Y))
+	g := &Group{
+		Parent:    u.group,
+		Owner:     u.group.Owner,
+		Transform: &t,
+		attrs:     u.attrs,
+		viewport:  u.group.viewport,
+	}
+	chain := append(u.chain[:len(u.chain):len(u.chain)], id)
+	return instantiate(target, g, chain), nil
+}
+
+// instantiate returns a copy of element e reparented to group g. The
+// descendants of groups are copied too. The chain of ids is passed
+// to any use elements encountered, so reference cycles are detected.
+func instantiate(e DrawingInstructionParser, g *Group, chain []string) DrawingInstructionParser {
+	switch e := e.(type) {
+	case *Group:
+		c := *e
+		c.Parent, c.Owner = g, g.Owner
+		c.updateStyle()
+		// The transform was validated when the group was decoded.
+		own, _ := parseTransform(c.TransformString)
+		t := mt.MultiplyTransforms(*g.Transform, own)
+		if c.tag == "svg" || c.tag == "symbol" {
+			// The element establishes a new viewport
+			// within that of the use element.
+			vp := c.newViewport(g.viewport)
+			t = mt.MultiplyTransforms(t, vp.transform())
+			c.viewport = vp.size()
+		}
+		c.Transform = &t
+		c.Elements = nil
+		for _, child := range e.Elements {
+			c.Elements = append(c.Elements, instantiate(child, &c, chain))
+		}
+		return &c
+	case *Use:
+		c := *e
+		c.group, c.chain = g, chain
+		return &c
+	case *Path:
+		c := *e
+		c.group = g
+		return &c
+	case *Rect:
+		c := *e
+		c.group = g
+		return &c
+	case *Circle:
+		c := *e
+		c.group = g
+		return &c
+	case *Ellipse:
+		c := *e
+		c.group = g
+		return &c
+	case *Line:
+		c := *e
+		c.group = g
+		return &c
+	case *Polygon:
+		c := *e
+		c.group = g
+		return &c
+	case *PolyLine:
+		c := *e
+		c.group = g
+		return &c
+	}
+	return e
+}
@@ -0,0 +1,208 @@
+package svger
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	mt "zappem.net/pub/graphics/svger/mtransform"
+	"zappem.net/pub/math/geom"
+)
+
+// aspectRatio holds a parsed preserveAspectRatio attribute value.
+type aspectRatio struct {
+	// alignX and alignY are the fractions (0, 0.5 or 1) of the
+	// unused viewport space placed before the viewBox. They are
+	// ignored if none is true.
+	alignX, alignY float64
+	// none indicates non-uniform scaling to fill the viewport.
+	none bool
+	// slice indicates the viewBox should cover the viewport rather
+	// than meet (fit inside) it.
+	slice bool
+}
+
+// parsePreserveAspectRatio parses a preserveAspectRatio attribute
+// value. The empty string yields the default, "xMidYMid meet".
+func parsePreserveAspectRatio(val string) (aspectRatio, error) {
+	par := aspectRatio{alignX: 0.5, alignY: 0.5}
+	fields := strings.Fields(val)
+	if len(fields) > 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return par, nil
+	}
+	if len(fields) > 2 {
+		return par, fmt.Errorf("invalid preserveAspectRatio %q", val)
+	}
+	align := fields[0]
+	if align == "none" {
+		par.none = true
+	} else {
+		fractions := map[string]float64{"Min": 0, "Mid": 0.5, "Max": 1}
+		if len(align) != 8 || align[0] != 'x' || align[4] != 'Y' {
+			return par, fmt.Errorf("invalid preserveAspectRatio alignment %q", align)
+		}
+		x, okX := fractions[align[1:4]]
+		y, okY := fractions[align[5:8]]
+		if !okX || !okY {
+			return par, fmt.Errorf("invalid preserveAspectRatio alignment %q", align)
+		}
+		par.alignX, par.alignY = x, y
+	}
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "meet":
+		case "slice":
+			par.slice = true
+		default:
+			return par, fmt.Errorf("invalid preserveAspectRatio %q", val)
+		}
+	}
+	return par, nil
+}
+
+// parseViewBox parses a viewBox attribute value into its minimum x,
+// minimum y, width and height values. The values may be separated by
+// white space and/or commas.
+func parseViewBox(val string) ([]float64, error) {
+	var vals []float64
+	for _, field := range strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+	}) {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return vals, err
+		}
+		vals = append(vals, v)
+	}
+	if len(vals) != 4 {
+		return vals, fmt.Errorf("viewBox %q requires 4 values, got %d", val, len(vals))
+	}
+	if vals[2] <= 0 || vals[3] <= 0 {
+		return vals, fmt.Errorf("viewBox %q must have a positive width and height", val)
+	}
+	return vals, nil
+}
+
+// parseLength parses an attribute value holding a length, returning
+// false if no valid length is present. Percentages are relative to
+// ref.
+func parseLength(val string, ref float64) (float64, bool) {
+	f, err := ParseLength(val, ref)
+	if err != nil && val != "" && Debug {
+		log.Printf("ignoring length: %v", err)
+	}
+	return f, err == nil
+}
+
+// viewport describes the area an svg element renders into and how its
+// content is mapped into it.
+type viewport struct {
+	// x, y, width and height are the viewport position and size in
+	// the user space of the parent.
+	x, y, width, height float64
+	// viewBox holds the parsed viewBox attribute, or nil.
+	viewBox []float64
+	par     aspectRatio
+}
+
+// newViewport determines the viewport of an svg element from its
+// attributes. The size of the parent viewport, which percentages are
+// relative to, is given by ref; it is zero for the outermost svg
+// element. Where no width or height can be determined, the
+// corresponding viewBox dimension is used, or the aspect ratio of the
+// viewBox is maintained if the other dimension is known.
+func newViewport(x, y, width, height, viewBox, preserveAspectRatio string, ref Tuple) viewport {
+	var vp viewport
+	vp.x, _ = parseLength(x, ref[0])
+	vp.y, _ = parseLength(y, ref[1])
+	if vb, err := parseViewBox(viewBox); err == nil {
+		vp.viewBox = vb
+	} else if viewBox != "" && Debug {
+		log.Printf("ignoring viewBox: %v", err)
+	}
+	if par, err := parsePreserveAspectRatio(preserveAspectRatio); err == nil {
+		vp.par = par
+	} else {
+		vp.par, _ = parsePreserveAspectRatio("")
+		if Debug {
+			log.Printf("ignoring preserveAspectRatio: %v", err)
+		}
+	}
+
+	hasWidth, hasHeight := false, false
+	if w, ok := parseLength(width, ref[0]); ok && (ref[0] > 0 || !strings.HasSuffix(width, "%")) {
+		vp.width, hasWidth = w, true
+	} else if ref[0] > 0 {
+		vp.width, hasWidth = ref[0], true
+	}
+	if h, ok := parseLength(height, ref[1]); ok && (ref[1] > 0 || !strings.HasSuffix(height, "%")) {
+		vp.height, hasHeight = h, true
+	} else if ref[1] > 0 {
+		vp.height, hasHeight = ref[1], true
+	}
+	if vb := vp.viewBox; vb != nil {
+		switch {
+		case !hasWidth && !hasHeight:
+			vp.width, vp.height = vb[2], vb[3]
+		case !hasWidth:
+			vp.width = vp.height * vb[2] / vb[3]
+		case !hasHeight:
+			vp.height = vp.width * vb[3] / vb[2]
+		}
+	}
+	return vp
+}
+
+// transform returns the transform that maps the user space of the
+// content of the svg element into the user space of its parent.
+func (vp viewport) transform() mt.Transform {
+	vb := vp.viewBox
+	if vb == nil {
+		return mt.Translate(vp.x, vp.y)
+	}
+	sx, sy := vp.width/vb[2], vp.height/vb[3]
+	tx, ty := vp.x, vp.y
+	if !vp.par.none {
+		s := math.Min(sx, sy)
+		if vp.par.slice {
+			s = math.Max(sx, sy)
+		}
+		sx, sy = s, s
+		tx += vp.par.alignX * (vp.width - vb[2]*sx)
+		ty += vp.par.alignY * (vp.height - vb[3]*sy)
+	}
+	return mt.Transform(geom.M(
+		sx, 0, tx-vb[0]*sx,
+		0, sy, ty-vb[1]*sy,
+		0, 0, 1))
+}
+
+// size returns the size of the viewport in the user space of its
+// content, which percentage lengths within it are relative to.
+func (vp viewport) size() Tuple {
+	if vp.viewBox != nil {
+		return Tuple{vp.viewBox[2], vp.viewBox[3]}
+	}
+	return Tuple{vp.width, vp.height}
+}
+
+// transformScale returns the factor by which transform t scales
+// lengths, such as stroke widths. For transforms that don't scale
+// uniformly, this is the geometric mean of the two scale factors.
+func transformScale(t *mt.Transform) float64 {
+	return math.Sqrt(math.Abs(t.Determinant()))
+}
+
+// isSimilarity returns true if t maps circles to circles.
+func isSimilarity(t *mt.Transform) bool {
+	m := geom.Matrix(*t)
+	ax, ay, bx, by := m[0], m[3], m[1], m[4]
+	a, b := math.Hypot(ax, ay), math.Hypot(bx, by)
+	const eps = 1e-12
+	return math.Abs(a-b) <= eps*(a+b) && math.Abs(ax*bx+ay*by) <= eps*a*b
+}